@@ -1,238 +1,1148 @@
 package catalog
 
 import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
-	"strings"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/adampresley/minitextindexer/config"
 	"github.com/adampresley/minitextindexer/document"
 	"github.com/adampresley/minitextindexer/tree"
 
-	"github.com/adampresley/directorywatcher"
 	"github.com/adampresley/logging"
 )
 
 /*
 A Catalog represents a physical file tree and its indexed, virtual tree.
+The index itself lives in a snapshot which readers load atomically, so
+searches never wait on a full index. Changes to single files, such as
+those reported by the directory watchers, are applied to the current
+snapshot in place, and searches wait briefly while each one is applied.
+The catalog mutex serializes writers, so such changes also wait for a
+running full index to finish.
 */
 type Catalog struct {
 	sync.Mutex
 
-	basePaths    []string
-	config       *config.Configuration
-	log          *logging.Logger
-	textPatterns []*config.TextPattern
-	tree         *tree.Tree
-	watchers     []*directorywatcher.DirectoryWatcher
+	cancelIndex context.CancelFunc
+	cancelRoot  context.CancelFunc
+	config      *config.Configuration
+	configLock  sync.RWMutex
+	current     atomic.Value
+	fileFilter  *fileFilter
+	indexLock   sync.Mutex
+	log         *logging.Logger
+	reloadLock  sync.Mutex
+	rootContext context.Context
+	status      IndexStatus
+	statusLock  sync.RWMutex
+	watchers    map[string]*pathWatcher
 }
 
-func (catalog *Catalog) compileRegexes() {
-	for index, textPattern := range catalog.textPatterns {
+/*
+AddMatchContext fills in the lines surrounding every match in a set of
+search results, with up to contextLines lines before and after each one.
+Files are read back when this is called, so matches in files which have
+changed since they were indexed are left without context rather than
+given the wrong lines. Files which are now too large or binary are not
+read, and their matches are left without context too. At most
+MaxContextFiles files and MaxContextBytes bytes are read, and matches in
+the rest of the files are left without context. The terms must be
+copies, such as those returned by Search, as their matches are replaced.
+*/
+func (catalog *Catalog) AddMatchContext(terms []*document.Term, contextLines int) {
+	configuration := catalog.GetConfig()
+	files := make(map[string]*contextFile)
+	filesRead := 0
+	bytesRead := int64(0)
+
+	for _, term := range terms {
+		for _, termDocument := range term.Documents {
+			file, ok := files[termDocument.DocumentName]
+
+			if !ok {
+				if filesRead < MaxContextFiles {
+					file = catalog.readContextFile(configuration, termDocument.DocumentName, MaxContextBytes-bytesRead)
+				}
+
+				if file != nil {
+					filesRead++
+					bytesRead += int64(len(file.Contents))
+				}
+
+				files[termDocument.DocumentName] = file
+			}
+
+			matches := make([]*document.PatternMatch, len(termDocument.Matches))
+
+			for index, match := range termDocument.Matches {
+				matchCopy := *match
+
+				if file != nil {
+					matchCopy.Context = file.Context(file.lineStarts, match, contextLines)
+				}
+
+				matches[index] = &matchCopy
+			}
+
+			termDocument.Matches = matches
+		}
+	}
+}
+
+/*
+applyConfiguration switches the catalog to a new configuration and
+starts, resumes, or stops directory watchers to match its paths, while
+holding the reload lock. It reports whether the change affects what is
+indexed. context.Canceled is returned once the catalog is stopped.
+*/
+func (catalog *Catalog) applyConfiguration(configuration *config.Configuration) (bool, error) {
+	catalog.reloadLock.Lock()
+	defer catalog.reloadLock.Unlock()
+
+	if catalog.isStopped() {
+		return false, context.Canceled
+	}
+
+	previous := catalog.GetConfig()
+	catalog.compileRegexes(configuration.TextPatterns)
+
+	filter := newFileFilter(configuration, catalog.log)
+
+	catalog.configLock.Lock()
+	catalog.config = configuration
+	catalog.fileFilter = filter
+	catalog.configLock.Unlock()
+
+	paths := make(map[string]bool)
+
+	for _, basePath := range configuration.Paths {
+		paths[basePath] = true
+
+		if watcher, ok := catalog.watchers[basePath]; !ok {
+			catalog.log.Infof("Watching new path %s", basePath)
+			catalog.watchers[basePath] = catalog.startWatcher(basePath)
+		} else if watcher.isStopped() {
+			catalog.log.Infof("Watching path %s again", basePath)
+			watcher.resume()
+		}
+	}
+
+	/*
+	 * Watchers of removed paths are kept, stopped, so they can be
+	 * resumed if the path is added back
+	 */
+	for basePath, watcher := range catalog.watchers {
+		if !paths[basePath] && !watcher.isStopped() {
+			catalog.log.Infof("No longer watching path %s", basePath)
+			watcher.stop()
+		}
+	}
+
+	unchanged := reflect.DeepEqual(previous.Paths, configuration.Paths) &&
+		reflect.DeepEqual(previous.FilePatterns, configuration.FilePatterns) &&
+		reflect.DeepEqual(previous.Include, configuration.Include) &&
+		reflect.DeepEqual(previous.Exclude, configuration.Exclude) &&
+		reflect.DeepEqual(previous.PathRules, configuration.PathRules) &&
+		previous.UseIgnoreFiles == configuration.UseIgnoreFiles &&
+		previous.FollowSymlinks == configuration.FollowSymlinks &&
+		previous.GetMaxFileSize() == configuration.GetMaxFileSize() &&
+		reflect.DeepEqual(patternFingerprints(previous), patternFingerprints(configuration))
+
+	return !unchanged, nil
+}
+
+/*
+comparePatterns compares the text pattern fingerprints an index was
+built with to the current ones. It returns the names of the patterns
+whose matches can be kept, and the text patterns which are new or have
+changed and must be scanned for.
+*/
+func comparePatterns(previous map[string]string, current map[string]string, textPatterns []*config.TextPattern) (map[string]bool, []*config.TextPattern) {
+	keptPatterns := make(map[string]bool)
+	var changedPatterns []*config.TextPattern
+
+	for _, textPattern := range textPatterns {
+		name := textPattern.GetName()
+
+		if fingerprint, ok := previous[name]; ok && fingerprint == current[name] {
+			keptPatterns[name] = true
+		} else {
+			changedPatterns = append(changedPatterns, textPattern)
+		}
+	}
+
+	return keptPatterns, changedPatterns
+}
+
+func (catalog *Catalog) compileRegexes(textPatterns []*config.TextPattern) {
+	for index, textPattern := range textPatterns {
 		exp, err := regexp.Compile(textPattern.Pattern)
 		if err != nil {
 			catalog.log.Errorf("Problem compiling regex [%s]: %s", textPattern.Pattern, err.Error())
 		} else {
-			catalog.textPatterns[index].Regex = exp
+			textPatterns[index].Regex = exp
 		}
 	}
 }
 
 /*
-FindTerm searches the tree for a specific term.
+FilterByPattern keeps only the matches produced by text patterns whose
+name or tags are in a list of labels. Documents and terms left without
+matches are dropped, and nil is returned if nothing is left. The terms
+must be copies, such as those returned by Search, as their documents are
+replaced.
+*/
+func (catalog *Catalog) FilterByPattern(terms []*document.Term, labels []string) []*document.Term {
+	patternNames := make(map[string]bool)
+
+	for _, textPattern := range catalog.GetConfig().TextPatterns {
+		for _, label := range labels {
+			if textPattern.HasLabel(label) {
+				patternNames[textPattern.GetName()] = true
+			}
+		}
+	}
+
+	var results []*document.Term
+
+	for _, term := range terms {
+		documents := make([]*document.Document, 0, len(term.Documents))
+
+		for _, termDocument := range term.Documents {
+			matches := make([]*document.PatternMatch, 0, len(termDocument.Matches))
+
+			for _, match := range termDocument.Matches {
+				if patternNames[match.Pattern] {
+					matches = append(matches, match)
+				}
+			}
+
+			if len(matches) > 0 {
+				termDocument.Matches = matches
+				documents = append(documents, termDocument)
+			}
+		}
+
+		if len(documents) > 0 {
+			term.Documents = documents
+			results = append(results, term)
+		}
+	}
+
+	return results
+}
+
+/*
+FindTerm searches the tree for a specific term. A copy of the term is
+returned so it stays consistent while the index changes.
 */
 func (catalog *Catalog) FindTerm(searchTerm string) *document.Term {
-	node := catalog.tree.Find(document.NewTerm(searchTerm))
+	current := catalog.getSnapshot()
+	current.RLock()
+	defer current.RUnlock()
+
+	node := current.tree.Find(document.NewTerm(searchTerm))
 
 	if node == nil {
 		return nil
 	}
 
-	return node.Value
+	return node.Value.Copy()
+}
+
+/*
+GetConfig returns the configuration the catalog is currently using. It
+changes when the configuration is reloaded.
+*/
+func (catalog *Catalog) GetConfig() *config.Configuration {
+	catalog.configLock.RLock()
+	defer catalog.configLock.RUnlock()
+
+	return catalog.config
+}
+
+func (catalog *Catalog) getFileFilter() *fileFilter {
+	catalog.configLock.RLock()
+	defer catalog.configLock.RUnlock()
+
+	return catalog.fileFilter
+}
+
+/*
+GetIndexErrors returns every file which could not be indexed, with the
+error and when it happened, sorted by name. A file is listed until it
+is indexed successfully or removed.
+*/
+func (catalog *Catalog) GetIndexErrors() []*FileError {
+	current := catalog.getSnapshot()
+	current.RLock()
+	defer current.RUnlock()
+
+	result := make([]*FileError, 0, len(current.failed))
+
+	for _, fileError := range current.failed {
+		result = append(result, fileError)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FileName < result[j].FileName
+	})
+
+	return result
+}
+
+/*
+GetIndexReport returns how many files are in the index, and every file
+which was skipped because it was too large or binary, sorted by name.
+*/
+func (catalog *Catalog) GetIndexReport() *IndexReport {
+	current := catalog.getSnapshot()
+	current.RLock()
+	defer current.RUnlock()
+
+	result := &IndexReport{
+		FileCount:    len(current.files),
+		SkippedCount: len(current.skipped),
+		SkippedFiles: make([]*SkippedFile, 0, len(current.skipped)),
+	}
+
+	for _, skippedFile := range current.skipped {
+		result.SkippedFiles = append(result.SkippedFiles, skippedFile)
+	}
+
+	sort.Slice(result.SkippedFiles, func(i, j int) bool {
+		return result.SkippedFiles[i].FileName < result.SkippedFiles[j].FileName
+	})
+
+	return result
 }
 
 /*
-Index creates the virtual index tree. This operation locks the
-catalog.
+GetStatus returns the progress of the current full index, or the result
+of the last one. Once the index is ready the number of terms reflects
+every change made since.
 */
-func (catalog *Catalog) Index() error {
+func (catalog *Catalog) GetStatus() IndexStatus {
+	catalog.statusLock.RLock()
+	result := catalog.status
+	catalog.statusLock.RUnlock()
+
+	switch result.Phase {
+	case IndexPhaseReady:
+		current := catalog.getSnapshot()
+		current.RLock()
+		result.Terms = current.tree.Count()
+		current.RUnlock()
+
+	case IndexPhaseDiscovering, IndexPhaseScanning:
+		/*
+		 * The total is only known once discovery is done, so until then
+		 * assume there are at least as many files as the last index had
+		 */
+		total := result.FilesDiscovered
+		if result.Phase == IndexPhaseDiscovering && result.expectedFiles > total {
+			total = result.expectedFiles
+		}
+
+		if result.FilesScanned > 0 && total > result.FilesScanned {
+			elapsed := time.Since(*result.StartedAt)
+			remaining := time.Duration(float64(elapsed) / float64(result.FilesScanned) * float64(total-result.FilesScanned))
+			eta := time.Now().Add(remaining)
+			result.ETA = &eta
+		}
+	}
+
+	return result
+}
+
+func (catalog *Catalog) getSnapshot() *snapshot {
+	return catalog.current.Load().(*snapshot)
+}
+
+/*
+hasFilesUnder reports whether the index knows of any files anywhere
+below a directory.
+*/
+func (catalog *Catalog) hasFilesUnder(directory string) bool {
+	current := catalog.getSnapshot()
+	current.RLock()
+	defer current.RUnlock()
+
+	return current.directories.contains(directory)
+}
+
+/*
+Index creates the virtual index tree. The directory walk hands matching
+files to a pool of workers which read and scan them in parallel. Their
+results are merged by a single goroutine into a new snapshot, which
+replaces the current one once it is complete. Searches keep using the
+previous snapshot until then. This operation locks the catalog against
+other writers for the whole run, but not against searches.
+
+Files whose modification time and size match the current snapshot,
+such as one loaded with LoadSnapshot, are not scanned for the text
+patterns which have not changed since. Their matches from those patterns
+are carried over instead, matches from removed or changed patterns are
+dropped, and the file is only scanned for added or changed patterns.
+Files larger than the maximum file size, and binary files unless they
+are to be indexed, are skipped and listed in the index report. When
+symlinks are followed each physical file is indexed once, under the
+first path it is found through. When a snapshot file is configured the
+new snapshot is saved to it. Progress is reported by GetStatus.
+
+Indexing stops promptly when the context is cancelled, when another
+full index is started, as that one supersedes it, or when the catalog
+is stopped. Its goroutines are stopped, the current snapshot is left as
+it was, and the context's error is returned.
+*/
+func (catalog *Catalog) Index(ctx context.Context) error {
 	startTime := time.Now()
 	fileCount := 0
+	reusedCount := 0
 	nodeCount := 0
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	catalog.indexLock.Lock()
+	if catalog.cancelIndex != nil {
+		catalog.cancelIndex()
+	}
+
+	if catalog.isStopped() {
+		cancel()
+	}
+
+	catalog.cancelIndex = cancel
+	catalog.indexLock.Unlock()
+
 	catalog.Lock()
+	defer catalog.Unlock()
 
-	doneChannel := make(chan bool)
-	indexChannel := make(chan document.DocumentIndex, 100)
-	waitGroup := &sync.WaitGroup{}
-
-	go func(waitGroup *sync.WaitGroup) {
-		for {
-			select {
-			case indexItem := <-indexChannel:
-				for key, newDocument := range indexItem {
-					/*
-					 * Create a new Term and add the document to it.
-					 */
-					termToFind := document.NewTerm(key)
-					termToFind.Documents = append(termToFind.Documents, newDocument)
-
-					/*
-					 * See if this term is already in the tree. If not, just add it.
-					 * If it is there, we need to see if we have this document captured
-					 * already. If not, add it. If so, only add our matches if we don't
-					 * already have those too.
-					 */
-					existingTermNode := catalog.tree.Find(termToFind)
-
-					if existingTermNode == nil {
-						catalog.tree.Add(termToFind)
-						nodeCount++
-					} else {
-						existingDocument := existingTermNode.FindDocument(newDocument.DocumentName)
-
-						if existingDocument == nil {
-							existingTermNode.Value.Documents = append(existingTermNode.Value.Documents, newDocument)
-						} else {
-							currentMatches := existingDocument.Matches
-
-							for _, newMatch := range newDocument.Matches {
-								if !existingDocument.HasMatchIndex(newMatch.Location) {
-									currentMatches = append(currentMatches, newMatch)
-								}
-							}
-
-							existingDocument.Matches = currentMatches
-						}
-					}
-				}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-				waitGroup.Done()
+	configuration := catalog.GetConfig()
+	filter := catalog.getFileFilter()
+	patterns := patternFingerprints(configuration)
+	current := catalog.getSnapshot()
+	keptPatterns, changedPatterns := comparePatterns(current.patterns, patterns, configuration.TextPatterns)
 
-			case <-doneChannel:
-				catalog.log.Debug("Done indexing catalog")
-				break
-			}
+	catalog.updateStatus(func(status *IndexStatus) {
+		if status.Phase == IndexPhaseReady {
+			status.expectedFiles = status.FilesDiscovered
 		}
-	}(waitGroup)
 
-	for _, basePath := range catalog.config.Paths {
-		filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
-			if !info.IsDir() {
-				/*
-				 * Only index this file if it matches the configured file pattern
-				 */
-				isFilePatternMatch := false
+		status.Bytes = 0
+		status.ETA = nil
+		status.FilesDiscovered = 0
+		status.FilesScanned = 0
+		status.Phase = IndexPhaseDiscovering
+		status.StartedAt = &startTime
+		status.Terms = 0
+	})
 
-				for _, filePattern := range catalog.config.FilePatterns {
-					if strings.Contains(path, filePattern) {
-						isFilePatternMatch = true
-						break
-					}
-				}
+	next := newSnapshot(patterns)
+	fileChannel := make(chan *fileIndex, 100)
+	indexChannel := make(chan *fileIndex, 100)
+	mergeDoneChannel := make(chan bool)
+	workerWaitGroup := &sync.WaitGroup{}
 
-				if !isFilePatternMatch {
-					return nil
+	go func() {
+		for indexItem := range indexChannel {
+			if ctx.Err() != nil {
+				continue
+			}
+
+			nodeCount += next.merge(indexItem)
+
+			catalog.updateStatus(func(status *IndexStatus) {
+				status.FilesScanned++
+				status.Terms = nodeCount
+
+				if indexItem.failed == nil && indexItem.skipped == nil {
+					status.Bytes += indexItem.metadata.Size
 				}
+			})
+		}
+
+		catalog.log.Debug("Done indexing catalog")
+		close(mergeDoneChannel)
+	}()
+
+	maxFileSize := configuration.GetMaxFileSize()
+	workerCount := configuration.GetIndexWorkers()
+	workerWaitGroup.Add(workerCount)
+
+	for worker := 0; worker < workerCount; worker++ {
+		go catalog.scanFiles(ctx, configuration, fileChannel, indexChannel, workerWaitGroup)
+	}
+
+	walker := newPathWalker(configuration.FollowSymlinks, catalog.log)
+
+	for _, basePath := range configuration.Paths {
+		if ctx.Err() != nil {
+			break
+		}
+
+		walker.walk(basePath, func(path string, info os.FileInfo, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if err != nil {
+				catalog.log.Errorf("Error walking path %s: %s", path, err.Error())
+				catalog.updateStatus(func(status *IndexStatus) {
+					status.FilesDiscovered++
+				})
+
+				return sendFileIndex(ctx, indexChannel, &fileIndex{
+					failed:   newFileError(path, err),
+					fileName: path,
+				})
+			}
 
+			if info.IsDir() {
+				if filter.isExcluded(basePath, path, true) {
+					return filepath.SkipDir
+				}
+			} else {
 				/*
-				 * Index this file
+				 * Only index this file if it matches the configured file patterns and globs
 				 */
+				if !filter.isMatch(basePath, path) {
+					return nil
+				}
+
 				fileCount++
-				waitGroup.Add(1)
+				catalog.updateStatus(func(status *IndexStatus) {
+					status.FilesDiscovered++
+				})
+
+				if info.Size() > maxFileSize {
+					return sendFileIndex(ctx, indexChannel, newSkippedFileIndex(path, info, tooLargeReason(maxFileSize)))
+				}
 
-				file := document.NewPhysicalFile(path, catalog.textPatterns)
-				_, err := file.Read()
-				if err != nil {
-					catalog.log.Errorf("Error reading file %s: %s", path, err.Error())
-					return err
+				if len(keptPatterns) > 0 {
+					if item := catalog.reuseFile(current, path, info, keptPatterns); item != nil {
+						if len(changedPatterns) > 0 {
+							item.textPatterns = changedPatterns
+							return sendFileIndex(ctx, fileChannel, item)
+						}
+
+						reusedCount++
+						return sendFileIndex(ctx, indexChannel, item)
+					}
 				}
 
-				index := file.CreateIndex()
-				indexChannel <- index
+				return sendFileIndex(ctx, fileChannel, &fileIndex{
+					fileName: path,
+					metadata: newFileMetadata(info),
+				})
 			}
 
 			return nil
 		})
 	}
 
-	waitGroup.Wait()
-	doneChannel <- true
-	catalog.Unlock()
+	catalog.updateStatus(func(status *IndexStatus) {
+		status.Phase = IndexPhaseScanning
+	})
+
+	close(fileChannel)
+	workerWaitGroup.Wait()
+
+	close(indexChannel)
+	<-mergeDoneChannel
+
+	if err := ctx.Err(); err != nil {
+		catalog.log.Infof("Indexing stopped after %s: %s", time.Since(startTime), err.Error())
+		catalog.updateStatus(func(status *IndexStatus) {
+			status.ETA = nil
+			status.Phase = IndexPhaseCancelled
+		})
+
+		return err
+	}
+
+	catalog.current.Store(next)
+
+	catalog.log.Infof("Time to index %d files (%d unchanged, %d skipped) with %d nodes using %d workers: %s", fileCount, reusedCount, len(next.skipped), nodeCount, workerCount, time.Since(startTime))
+
+	if configuration.SnapshotFile != "" {
+		catalog.updateStatus(func(status *IndexStatus) {
+			status.Phase = IndexPhaseSaving
+		})
+
+		if err := catalog.SaveSnapshot(configuration.SnapshotFile); err != nil {
+			catalog.log.Errorf("Error saving index snapshot %s: %s", configuration.SnapshotFile, err.Error())
+		}
+	}
+
+	catalog.updateStatus(func(status *IndexStatus) {
+		completedTime := time.Now()
+
+		status.LastCompleted = &completedTime
+		status.Phase = IndexPhaseReady
+	})
 
-	catalog.log.Infof("Time to index %d files with %d nodes: %s", fileCount, nodeCount, time.Since(startTime))
 	return nil
 }
 
+/*
+IndexFile rescans a single file and merges its matches into the tree,
+replacing any matches previously recorded for that file. A file which
+is now too large or binary is removed from the tree and recorded as
+skipped instead. A file which cannot be read is removed from the tree
+and its error recorded, and the error is returned. This operation
+locks the catalog, and briefly blocks searches while the change is
+applied.
+*/
+func (catalog *Catalog) IndexFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	item := &fileIndex{
+		fileName: path,
+		metadata: newFileMetadata(info),
+	}
+
+	catalog.scanFile(catalog.GetConfig(), item)
+
+	if item.skipped != nil {
+		catalog.log.Infof("Skipping file %s: %s", path, item.skipped.Reason)
+	}
+
+	catalog.Lock()
+	defer catalog.Unlock()
+
+	current := catalog.getSnapshot()
+	current.Lock()
+	defer current.Unlock()
+
+	current.remove(path)
+	current.merge(item)
+
+	if item.failed != nil {
+		return errors.New(item.failed.Reason)
+	}
+
+	return nil
+}
+
+/*
+IsComplete reports whether a full index has completed. Until then
+searches only see part of the files, or those of a loaded snapshot
+which may be out of date.
+*/
+func (catalog *Catalog) IsComplete() bool {
+	catalog.statusLock.RLock()
+	defer catalog.statusLock.RUnlock()
+
+	return catalog.status.LastCompleted != nil
+}
+
+/*
+isIndexed reports whether a file is in the index, either indexed or
+recorded as skipped.
+*/
+func (catalog *Catalog) isIndexed(path string) bool {
+	current := catalog.getSnapshot()
+	current.RLock()
+	defer current.RUnlock()
+
+	if _, ok := current.skipped[path]; ok {
+		return true
+	}
+
+	_, ok := current.documentTerms[path]
+	return ok
+}
+
+func (catalog *Catalog) isStopped() bool {
+	return catalog.rootContext.Err() != nil
+}
+
 /*
 NewCatalog returns a new instance of a Catalog structure. It will
 create the initial index and start a directory watcher for the physical
 file structure.
 */
 func NewCatalog(log *logging.Logger, config *config.Configuration) *Catalog {
-	// TODO: Using "mn" as a root node. This may need to be calculated based on found elements.
-	// If too many files come on side or the other of "mn" we will have an unbalanced tree.
 	catalog := &Catalog{
-		basePaths:    config.Paths,
-		config:       config,
-		log:          log,
-		textPatterns: config.TextPatterns,
-		tree:         tree.NewTree(document.NewTerm("mn")),
+		config:     config,
+		fileFilter: newFileFilter(config, log),
+		log:        log,
+		status:     IndexStatus{Phase: IndexPhaseStarting},
+		watchers:   make(map[string]*pathWatcher),
 	}
 
-	/*
-	 * Define a directory watcher function to be used by each directory watcher
-	 */
-	watcherFunc := func(path string, info os.FileInfo, startTime time.Time, modificationTime time.Time) error {
-		isFilePatternMatch := false
+	catalog.rootContext, catalog.cancelRoot = context.WithCancel(context.Background())
+	catalog.compileRegexes(config.TextPatterns)
+	catalog.current.Store(newSnapshot(nil))
 
-		for _, filePattern := range config.FilePatterns {
-			if strings.Contains(path, filePattern) {
-				isFilePatternMatch = true
-				break
-			}
+	for _, basePath := range config.Paths {
+		catalog.watchers[basePath] = catalog.startWatcher(basePath)
+	}
+
+	return catalog
+}
+
+/*
+patternFingerprints returns, for the name of each text pattern, a hash
+of the settings which decide what is indexed for it: the text patterns
+with that name, and whether binary files are indexed. It tells which of
+an existing index's matches were found the same way, as matches are
+labelled with the name of the pattern which found them.
+*/
+func patternFingerprints(configuration *config.Configuration) map[string]string {
+	textPatterns := make(map[string][]*config.TextPattern)
+
+	for _, textPattern := range configuration.TextPatterns {
+		textPatterns[textPattern.GetName()] = append(textPatterns[textPattern.GetName()], textPattern)
+	}
+
+	result := make(map[string]string, len(textPatterns))
+
+	for name, namedPatterns := range textPatterns {
+		settings, _ := json.Marshal(namedPatterns)
+
+		if configuration.IndexBinaryFiles {
+			settings = append(settings, []byte("+binary")...)
 		}
 
-		if isFilePatternMatch {
-			log.Infof("Detected change in path %s", path)
+		hash := sha1.Sum(settings)
+		result[name] = hex.EncodeToString(hash[:])
+	}
+
+	return result
+}
+
+/*
+readContextFile reads a file back to find the context of its matches,
+working out where its lines start once. It returns nil if the file
+cannot be read, is now too large or binary, or is larger than the bytes
+left to read for this search.
+*/
+func (catalog *Catalog) readContextFile(configuration *config.Configuration, fileName string, bytesLeft int64) *contextFile {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		catalog.log.Errorf("Error reading file %s for match context: %s", fileName, err.Error())
+		return nil
+	}
+
+	if info.Size() > bytesLeft {
+		catalog.log.Debugf("Not reading file %s for match context: the search has read its limit of %d bytes", fileName, MaxContextBytes)
+		return nil
+	}
+
+	file := document.NewPhysicalFile(fileName, nil)
+
+	reason, err := skipReason(configuration, file, info.Size())
+	if err != nil {
+		catalog.log.Errorf("Error reading file %s for match context: %s", fileName, err.Error())
+		return nil
+	}
+
+	if reason != "" {
+		catalog.log.Debugf("Not reading file %s for match context: %s", fileName, reason)
+		return nil
+	}
+
+	if _, err := file.Read(); err != nil {
+		catalog.log.Errorf("Error reading file %s for match context: %s", fileName, err.Error())
+		return nil
+	}
+
+	return &contextFile{
+		PhysicalFile: file,
+		lineStarts:   file.LineStarts(),
+	}
+}
+
+/*
+Reload switches the catalog to a new configuration, which should
+already have been validated. Directory watchers are started for added
+paths and stopped for removed ones. The index is then rebuilt only if
+the paths, file patterns, globs, or text patterns changed. Files which are
+still indexed and have not changed are carried over, so only new files
+are scanned. When text patterns are added or changed every file is
+scanned again, but only for those patterns, and the matches of removed
+patterns are dropped without scanning. A full index which is already
+running for an earlier configuration is stopped first.
+*/
+func (catalog *Catalog) Reload(ctx context.Context, configuration *config.Configuration) error {
+	affectsIndex, err := catalog.applyConfiguration(configuration)
+	if err != nil {
+		return err
+	}
+
+	if !affectsIndex {
+		catalog.log.Info("Configuration reloaded. The index is not affected")
+		return nil
+	}
+
+	catalog.log.Info("Configuration reloaded. Reindexing...")
+	return catalog.Index(ctx)
+}
+
+/*
+removeDeleted removes every file at or below a path which is in the
+index but no longer exists, such as the files of a deleted or renamed
+directory.
+*/
+func (catalog *Catalog) removeDeleted(path string) {
+	current := catalog.getSnapshot()
+	current.RLock()
+	fileNames := current.directories.fileNamesUnder(filepath.Clean(path))
+	current.RUnlock()
+
+	catalog.removeMissing(fileNames)
+}
+
+/*
+RemoveDocument removes a file from the index. Terms which no longer
+reference any document are pruned from the tree. This operation locks
+the catalog, and briefly blocks searches while the change is applied.
+*/
+func (catalog *Catalog) RemoveDocument(documentName string) {
+	catalog.Lock()
+	defer catalog.Unlock()
+
+	current := catalog.getSnapshot()
+	current.Lock()
+	defer current.Unlock()
+
+	current.remove(documentName)
+}
+
+/*
+removeMissing removes the files in a list which no longer exist from
+the index. The files are checked without holding any lock. The catalog
+is only locked, and searches only briefly blocked, to remove those
+which are gone.
+*/
+func (catalog *Catalog) removeMissing(fileNames []string) {
+	var missing []string
+
+	for _, fileName := range fileNames {
+		if _, err := os.Stat(fileName); os.IsNotExist(err) {
+			missing = append(missing, fileName)
 		}
+	}
+
+	if len(missing) == 0 {
+		return
+	}
 
+	catalog.Lock()
+	defer catalog.Unlock()
+
+	current := catalog.getSnapshot()
+	current.Lock()
+	defer current.Unlock()
+
+	for _, fileName := range missing {
+		catalog.log.Infof("Removing deleted file %s", fileName)
+		current.remove(fileName)
+	}
+}
+
+/*
+reuseFile returns the existing entries for a file from the kept text
+patterns if the file has not changed since it was indexed in a
+snapshot, so they can be merged without scanning it for those patterns
+again. It returns nil if the file needs to be scanned in full.
+*/
+func (catalog *Catalog) reuseFile(existing *snapshot, path string, info os.FileInfo, keptPatterns map[string]bool) *fileIndex {
+	existing.RLock()
+	defer existing.RUnlock()
+
+	if !existing.isUnchanged(path, info) {
 		return nil
 	}
 
-	catalog.watchers = make([]*directorywatcher.DirectoryWatcher, len(config.Paths))
+	return existing.fileIndex(path, keptPatterns)
+}
 
-	for index, basePath := range config.Paths {
-		catalog.watchers[index] = directorywatcher.NewDirectoryWatcher(basePath, log)
-		catalog.watchers[index].Watch(watcherFunc)
+/*
+scanFile reads and scans a single file, filling in its index. A file
+carried over from an earlier index is only scanned for the text
+patterns it lists, and the matches found are added to those it already
+has. If the file is skipped or cannot be read the reason or error is
+filled in instead.
+*/
+func (catalog *Catalog) scanFile(configuration *config.Configuration, item *fileIndex) {
+	textPatterns := configuration.TextPatterns
+	if item.textPatterns != nil {
+		textPatterns = item.textPatterns
 	}
 
-	catalog.compileRegexes()
-	return catalog
+	file := document.NewPhysicalFile(item.fileName, textPatterns)
+
+	reason, err := skipReason(configuration, file, item.metadata.Size)
+	if err != nil {
+		item.failed = newFileError(item.fileName, err)
+		return
+	}
+
+	if reason != "" {
+		item.skipped = &SkippedFile{
+			FileName: item.fileName,
+			Reason:   reason,
+			Size:     item.metadata.Size,
+		}
+
+		return
+	}
+
+	if _, err := file.Read(); err != nil {
+		item.failed = newFileError(item.fileName, err)
+		return
+	}
+
+	index := file.CreateIndex()
+
+	if item.index == nil {
+		item.index = index
+		return
+	}
+
+	for key, newDocument := range index {
+		if existingDocument, ok := item.index[key]; ok {
+			existingDocument.Matches = append(existingDocument.Matches, newDocument.Matches...)
+		} else {
+			item.index[key] = newDocument
+		}
+	}
 }
 
 /*
-Search searches the tree for nodes containing a term. This uses a depth-first
-pre-order traversal.
+scanFiles is run by each indexing worker. It reads and scans the files
+sent on the file channel, and sends their indexes on to be merged.
+Binary files are sent on as skipped unless they are to be indexed, and
+files which cannot be read are sent on with their error so the rest
+are still indexed. Files which are too large never reach the workers.
+Once the context is cancelled the rest of the files are drained from
+the channel without being read.
 */
-func (catalog *Catalog) Search(searchTerm string) []*document.Term {
-	nodes := catalog.tree.Search(searchTerm)
+func (catalog *Catalog) scanFiles(ctx context.Context, configuration *config.Configuration, fileChannel chan *fileIndex, indexChannel chan *fileIndex, waitGroup *sync.WaitGroup) {
+	defer waitGroup.Done()
 
-	if nodes == nil {
+	for item := range fileChannel {
+		if ctx.Err() != nil {
+			continue
+		}
+
+		catalog.scanFile(configuration, item)
+
+		if item.failed != nil {
+			catalog.log.Errorf("Error reading file %s: %s", item.fileName, item.failed.Reason)
+		}
+
+		sendFileIndex(ctx, indexChannel, item)
+	}
+}
+
+/*
+sendFileIndex sends an item on a channel unless the context is
+cancelled first, in which case the context's error is returned.
+*/
+func sendFileIndex(ctx context.Context, channel chan *fileIndex, item *fileIndex) error {
+	select {
+	case channel <- item:
 		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/*
+Search searches the tree for nodes matching a term. How the term is
+matched depends on the search mode (see SearchMode). A blank mode means
+a contains search, which checks only the candidate keys found in the
+trigram index. Fuzzy searches return keys within maxDistance edits of
+the search term ranked by distance; maxDistance is ignored by other
+modes. All other results are returned in lexical order. An error is
+returned for an unknown mode, an out of range distance, or an invalid
+regex or glob pattern. The terms returned are copies, so they stay
+consistent while the index changes.
+*/
+func (catalog *Catalog) Search(searchTerm string, mode string, maxDistance int) ([]*document.Term, error) {
+	var nodes []*tree.Node
+
+	searchMode, err := parseSearchMode(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	current := catalog.getSnapshot()
+	current.RLock()
+	defer current.RUnlock()
+
+	switch searchMode {
+	case SearchModeFuzzy:
+		if maxDistance < 0 || maxDistance > MaxFuzzyDistance {
+			return nil, fmt.Errorf("fuzzy distance must be between 0 and %d", MaxFuzzyDistance)
+		}
+
+		matches := current.fuzzyKeys.Search(searchTerm, maxDistance)
+		keys := make([]string, len(matches))
+
+		for index, match := range matches {
+			keys[index] = match.Key
+		}
+
+		nodes = current.findTerms(keys)
+
+	case SearchModeGlob:
+		exp, literalPrefix, err := compileGlob(searchTerm)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, node := range current.tree.SearchPrefix(literalPrefix) {
+			if exp.MatchString(node.Value.Key) {
+				nodes = append(nodes, node)
+			}
+		}
+
+	case SearchModePrefix:
+		nodes = current.tree.SearchPrefix(searchTerm)
+
+	case SearchModeRegex:
+		exp, err := compileRegex(searchTerm)
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = current.tree.Filter(exp.MatchString)
+
+	default:
+		nodes = current.findTerms(current.trigrams.Contains(searchTerm))
+	}
+
+	if nodes == nil {
+		return nil, nil
 	}
 
 	results := make([]*document.Term, len(nodes))
 
 	for index, node := range nodes {
-		results[index] = node.Value
+		results[index] = node.Value.Copy()
 	}
 
-	return results
+	return results, nil
+}
+
+/*
+Stop stops watching the configured paths for changes and stops any full
+index which is running, waiting for it to finish with the catalog. No
+full index or configuration reload will run after this. The index is
+left as it is, so it can still be searched or saved.
+*/
+func (catalog *Catalog) Stop() {
+	catalog.indexLock.Lock()
+	catalog.cancelRoot()
+
+	if catalog.cancelIndex != nil {
+		catalog.cancelIndex()
+	}
+
+	catalog.indexLock.Unlock()
+
+	catalog.reloadLock.Lock()
+	for _, watcher := range catalog.watchers {
+		watcher.stop()
+	}
+
+	catalog.reloadLock.Unlock()
+
+	/*
+	 * Wait for a cancelled index to let go of the catalog
+	 */
+	catalog.Lock()
+	catalog.Unlock()
+}
+
+/*
+syncDirectory reconciles the index with a changed directory. Indexed
+files directly inside it which no longer exist are removed, as is
+everything under subdirectories which no longer exist, such as one
+which was deleted or renamed. Matching files directly inside it which
+have not been indexed yet are indexed, as is every matching file under
+subdirectories the index has no files under yet, such as the new name
+of a renamed subdirectory. Subdirectories which are already indexed are
+not walked, as the directory watcher reports their own changes, and
+neither are excluded ones. The directory must be under the given base
+path.
+*/
+func (catalog *Catalog) syncDirectory(basePath, directory string) {
+	directory = filepath.Clean(directory)
+
+	current := catalog.getSnapshot()
+	current.RLock()
+	fileNames := current.directories.fileNamesIn(directory)
+	subdirectories := current.directories.subdirectoriesOf(directory)
+	current.RUnlock()
+
+	catalog.removeMissing(fileNames)
+
+	for _, subdirectory := range subdirectories {
+		if _, err := os.Stat(subdirectory); os.IsNotExist(err) {
+			catalog.removeDeleted(subdirectory)
+		}
+	}
+
+	if _, err := os.Stat(directory); os.IsNotExist(err) {
+		return
+	}
+
+	filter := catalog.getFileFilter()
+	walker := newPathWalker(catalog.GetConfig().FollowSymlinks, catalog.log)
+
+	walker.walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			catalog.log.Errorf("Error reading directory %s: %s", path, err.Error())
+			return nil
+		}
+
+		if info.IsDir() {
+			if path != directory && (filter.isExcluded(basePath, path, true) || catalog.hasFilesUnder(path)) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !filter.isMatch(basePath, path) || catalog.isIndexed(path) {
+			return nil
+		}
+
+		catalog.log.Infof("Indexing new file %s", path)
+
+		if err := catalog.IndexFile(path); err != nil {
+			catalog.log.Errorf("Error indexing file %s: %s", path, err.Error())
+		}
+
+		return nil
+	})
 }
 
 /*
@@ -240,13 +1150,25 @@ ToJSON returns a pretty printed string of this catalog as JSON
 */
 func (catalog *Catalog) ToJSON() string {
 	result := make(map[string]interface{})
-	catalog.Lock()
 
-	result["tree"] = catalog.tree
-	result["basePaths"] = catalog.basePaths
+	current := catalog.getSnapshot()
+	current.RLock()
+
+	result["tree"] = current.tree
+	result["basePaths"] = catalog.GetConfig().Paths
 
 	bytes, _ := json.MarshalIndent(result, "", "   ")
 
-	catalog.Unlock()
+	current.RUnlock()
 	return string(bytes)
 }
+
+/*
+updateStatus changes the index status while holding its lock.
+*/
+func (catalog *Catalog) updateStatus(update func(status *IndexStatus)) {
+	catalog.statusLock.Lock()
+	defer catalog.statusLock.Unlock()
+
+	update(&catalog.status)
+}
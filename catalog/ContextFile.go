@@ -0,0 +1,13 @@
+package catalog
+
+import "github.com/adampresley/minitextindexer/document"
+
+/*
+contextFile is a file read back to find the context of its matches,
+along with where each of its lines start.
+*/
+type contextFile struct {
+	*document.PhysicalFile
+
+	lineStarts []int
+}
@@ -0,0 +1,136 @@
+package catalog
+
+import "path/filepath"
+
+/*
+directoryIndex groups the files a snapshot knows of by directory, and
+links each directory to the subdirectories holding such files, so the
+files in or under a directory are found without looking at every file
+in the index. A directory is only in the index while there are files
+somewhere below it.
+*/
+type directoryIndex struct {
+	directories map[string]map[string]bool
+	files       map[string]map[string]bool
+}
+
+func newDirectoryIndex() *directoryIndex {
+	return &directoryIndex{
+		directories: make(map[string]map[string]bool),
+		files:       make(map[string]map[string]bool),
+	}
+}
+
+/*
+add records a file under its directory, linking the directory to each
+of its parents. Adding a file which is already recorded does nothing.
+*/
+func (index *directoryIndex) add(fileName string) {
+	directory := filepath.Dir(fileName)
+
+	if index.files[directory] == nil {
+		index.files[directory] = make(map[string]bool)
+	}
+
+	index.files[directory][fileName] = true
+
+	for {
+		parent := filepath.Dir(directory)
+		if parent == directory || index.directories[parent][directory] {
+			return
+		}
+
+		if index.directories[parent] == nil {
+			index.directories[parent] = make(map[string]bool)
+		}
+
+		index.directories[parent][directory] = true
+		directory = parent
+	}
+}
+
+/*
+contains reports whether there are any files at or below a path.
+*/
+func (index *directoryIndex) contains(path string) bool {
+	return index.files[filepath.Dir(path)][path] || len(index.files[path]) > 0 || len(index.directories[path]) > 0
+}
+
+/*
+fileNamesIn returns the files directly inside a directory.
+*/
+func (index *directoryIndex) fileNamesIn(directory string) []string {
+	result := make([]string, 0, len(index.files[directory]))
+
+	for fileName := range index.files[directory] {
+		result = append(result, fileName)
+	}
+
+	return result
+}
+
+/*
+fileNamesUnder returns the file at a path, if there is one, and every
+file anywhere below it. Only the directories below the path are visited.
+*/
+func (index *directoryIndex) fileNamesUnder(path string) []string {
+	var result []string
+
+	if index.files[filepath.Dir(path)][path] {
+		result = append(result, path)
+	}
+
+	pending := []string{path}
+
+	for len(pending) > 0 {
+		directory := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		for fileName := range index.files[directory] {
+			result = append(result, fileName)
+		}
+
+		for subdirectory := range index.directories[directory] {
+			pending = append(pending, subdirectory)
+		}
+	}
+
+	return result
+}
+
+/*
+remove forgets a file, along with any directories left without files
+below them.
+*/
+func (index *directoryIndex) remove(fileName string) {
+	directory := filepath.Dir(fileName)
+
+	delete(index.files[directory], fileName)
+
+	for len(index.files[directory]) == 0 && len(index.directories[directory]) == 0 {
+		delete(index.files, directory)
+		delete(index.directories, directory)
+
+		parent := filepath.Dir(directory)
+		if parent == directory {
+			return
+		}
+
+		delete(index.directories[parent], directory)
+		directory = parent
+	}
+}
+
+/*
+subdirectoriesOf returns the directories directly inside a directory
+which have files somewhere below them.
+*/
+func (index *directoryIndex) subdirectoriesOf(directory string) []string {
+	result := make([]string, 0, len(index.directories[directory]))
+
+	for subdirectory := range index.directories[directory] {
+		result = append(result, subdirectory)
+	}
+
+	return result
+}
@@ -0,0 +1,23 @@
+package catalog
+
+import (
+	"time"
+)
+
+/*
+A FileError records a file which could not be indexed, such as one
+which could not be read, along with the error and when it happened.
+*/
+type FileError struct {
+	FileName  string    `json:"fileName"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func newFileError(fileName string, err error) *FileError {
+	return &FileError{
+		FileName:  fileName,
+		Reason:    err.Error(),
+		Timestamp: time.Now(),
+	}
+}
@@ -0,0 +1,183 @@
+package catalog
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/adampresley/minitextindexer/config"
+	"github.com/adampresley/minitextindexer/glob"
+	"github.com/adampresley/minitextindexer/ignore"
+
+	"github.com/adampresley/logging"
+)
+
+/*
+fileFilter decides which files under the configured paths are indexed.
+A file must contain one of the file patterns, if there are any, match
+one of the include globs, if there are any, and neither it nor any
+directory above it may match an exclude glob or, when enabled, be
+ignored by an ignore file. Globs are matched against the path relative
+to the configured path the file is under.
+*/
+type fileFilter struct {
+	filePatterns []string
+	global       *pathFilter
+	ignore       *ignore.Matcher
+	paths        map[string]*pathFilter
+}
+
+type pathFilter struct {
+	exclude []*glob.Pattern
+	include []*glob.Pattern
+}
+
+/*
+newFileFilter compiles the include and exclude globs of a
+configuration. Globs which do not compile are logged and skipped.
+*/
+func newFileFilter(configuration *config.Configuration, log *logging.Logger) *fileFilter {
+	compile := func(patterns []string) []*glob.Pattern {
+		var result []*glob.Pattern
+
+		for _, pattern := range patterns {
+			compiled, err := glob.Compile(pattern)
+			if err != nil {
+				log.Errorf("Problem compiling glob [%s]: %s", pattern, err.Error())
+				continue
+			}
+
+			result = append(result, compiled)
+		}
+
+		return result
+	}
+
+	result := &fileFilter{
+		filePatterns: configuration.FilePatterns,
+		global: &pathFilter{
+			exclude: compile(configuration.Exclude),
+			include: compile(configuration.Include),
+		},
+		paths: make(map[string]*pathFilter),
+	}
+
+	if configuration.UseIgnoreFiles {
+		result.ignore = ignore.NewMatcher()
+	}
+
+	for _, basePath := range configuration.Paths {
+		pathRule := configuration.GetPathRule(basePath)
+		if pathRule == nil {
+			continue
+		}
+
+		rules := &pathFilter{
+			exclude: append(compile(pathRule.Exclude), result.global.exclude...),
+			include: result.global.include,
+		}
+
+		if len(pathRule.Include) > 0 {
+			rules.include = compile(pathRule.Include)
+		}
+
+		result.paths[filepath.Clean(basePath)] = rules
+	}
+
+	return result
+}
+
+func (filter *fileFilter) getPathFilter(basePath string) *pathFilter {
+	if rules, ok := filter.paths[filepath.Clean(basePath)]; ok {
+		return rules
+	}
+
+	return filter.global
+}
+
+/*
+isExcluded reports whether a file or directory, or any directory
+between it and the base path, matches an exclude glob or is ignored.
+*/
+func (filter *fileFilter) isExcluded(basePath, path string, isDirectory bool) bool {
+	if filter.ignore != nil && filter.ignore.IsIgnored(basePath, path, isDirectory) {
+		return true
+	}
+
+	rules := filter.getPathFilter(basePath)
+	if len(rules.exclude) == 0 {
+		return false
+	}
+
+	relativePath := relativeTo(basePath, path)
+	if relativePath == "." {
+		return false
+	}
+
+	for {
+		for _, pattern := range rules.exclude {
+			if pattern.Match(relativePath) {
+				return true
+			}
+		}
+
+		index := strings.LastIndex(relativePath, "/")
+		if index < 0 {
+			return false
+		}
+
+		relativePath = relativePath[:index]
+	}
+}
+
+/*
+isMatch reports whether a file under a base path should be indexed.
+*/
+func (filter *fileFilter) isMatch(basePath, path string) bool {
+	if len(filter.filePatterns) > 0 {
+		found := false
+
+		for _, filePattern := range filter.filePatterns {
+			if strings.Contains(path, filePattern) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	rules := filter.getPathFilter(basePath)
+
+	if len(rules.include) > 0 {
+		relativePath := relativeTo(basePath, path)
+		found := false
+
+		for _, pattern := range rules.include {
+			if pattern.Match(relativePath) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return !filter.isExcluded(basePath, path, false)
+}
+
+/*
+relativeTo returns a path relative to a base path with forward slashes,
+which is the form globs are matched against.
+*/
+func relativeTo(basePath, path string) string {
+	relativePath, err := filepath.Rel(basePath, path)
+	if err != nil {
+		relativePath = path
+	}
+
+	return filepath.ToSlash(relativePath)
+}
@@ -0,0 +1,11 @@
+package catalog
+
+/*
+An IndexReport describes what is in the index: how many files are
+indexed, and which files were skipped and why.
+*/
+type IndexReport struct {
+	FileCount    int            `json:"fileCount"`
+	SkippedCount int            `json:"skippedCount"`
+	SkippedFiles []*SkippedFile `json:"skippedFiles"`
+}
@@ -0,0 +1,48 @@
+package catalog
+
+import (
+	"time"
+)
+
+/*
+IndexPhase is the stage the catalog's indexing is in.
+*/
+type IndexPhase string
+
+/*
+Indexing phases. A full index starts out discovering files while the
+ones already found are scanned, scans the rest once every path has been
+walked, and saves the snapshot file if one is configured. An index which
+is stopped before it completes is cancelled.
+*/
+const (
+	IndexPhaseCancelled   IndexPhase = "cancelled"
+	IndexPhaseDiscovering IndexPhase = "discovering"
+	IndexPhaseReady       IndexPhase = "ready"
+	IndexPhaseSaving      IndexPhase = "saving"
+	IndexPhaseScanning    IndexPhase = "scanning"
+	IndexPhaseStarting    IndexPhase = "starting"
+)
+
+/*
+IndexStatus describes the progress of the current full index, or of the
+last one once it has completed. FilesDiscovered counts the files found
+by the walk so far, and FilesScanned those which have been indexed,
+skipped, or failed. Bytes is the total size of the files indexed. ETA
+is an estimate of when the index will complete, and is only given while
+indexing once enough is known to make one. LastCompleted is when a full
+index last completed, and is nil until the first one has, in which case
+the index is incomplete.
+*/
+type IndexStatus struct {
+	Bytes           int64      `json:"bytes"`
+	ETA             *time.Time `json:"eta,omitempty"`
+	FilesDiscovered int        `json:"filesDiscovered"`
+	FilesScanned    int        `json:"filesScanned"`
+	LastCompleted   *time.Time `json:"lastCompleted,omitempty"`
+	Phase           IndexPhase `json:"phase"`
+	StartedAt       *time.Time `json:"startedAt,omitempty"`
+	Terms           int        `json:"terms"`
+
+	expectedFiles int
+}
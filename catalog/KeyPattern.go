@@ -0,0 +1,68 @@
+package catalog
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/adampresley/minitextindexer/glob"
+)
+
+/*
+MaxKeyPatternLength is the longest regex or glob pattern a search may
+use. Go regular expressions run in linear time, so this together with
+the repetition limits of the regexp package keeps a single query from
+tying up the server.
+*/
+const MaxKeyPatternLength = 256
+
+/*
+compileGlob converts a glob pattern into a case insensitive regular
+expression which must match a whole key. A * matches any run of
+characters, ? matches a single character, [abc] and [!abc] match
+character classes, and a backslash escapes the next character. The
+literal text before the first wildcard is returned so the search can be
+narrowed to that key prefix.
+*/
+func compileGlob(pattern string) (*regexp.Regexp, string, error) {
+	if err := checkKeyPatternLength(pattern); err != nil {
+		return nil, "", err
+	}
+
+	expression, literalPrefix, err := glob.Translate(pattern, false)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid glob pattern %s: %s", pattern, err.Error())
+	}
+
+	exp, err := regexp.Compile("(?is)^" + expression + "$")
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid glob pattern %s: %s", pattern, err.Error())
+	}
+
+	return exp, literalPrefix, nil
+}
+
+/*
+compileRegex compiles a regular expression search. The expression is
+matched against keys as written, so it is case sensitive unless it
+starts with (?i).
+*/
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if err := checkKeyPatternLength(pattern); err != nil {
+		return nil, err
+	}
+
+	exp, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern %s: %s", pattern, err.Error())
+	}
+
+	return exp, nil
+}
+
+func checkKeyPatternLength(pattern string) error {
+	if len(pattern) > MaxKeyPatternLength {
+		return fmt.Errorf("pattern is longer than %d characters", MaxKeyPatternLength)
+	}
+
+	return nil
+}
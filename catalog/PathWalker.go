@@ -0,0 +1,121 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/adampresley/logging"
+)
+
+/*
+pathWalker walks the files under the configured paths. Unless symlinks
+are followed this is a plain filepath.Walk, which does not descend into
+linked directories.
+
+When symlinks are followed every directory is walked by its real path,
+with the paths handed to the walk function rewritten to the path the
+directory was found through. A directory reached more than once, such
+as through a link which points back above itself, is only walked the
+first time, and each physical file is only visited once however many
+links lead to it. One walker should be used for every path of an index
+so this holds across all of them.
+*/
+type pathWalker struct {
+	followSymlinks     bool
+	log                *logging.Logger
+	visitedDirectories map[string]bool
+	visitedFiles       map[string]bool
+}
+
+func newPathWalker(followSymlinks bool, log *logging.Logger) *pathWalker {
+	return &pathWalker{
+		followSymlinks:     followSymlinks,
+		log:                log,
+		visitedDirectories: make(map[string]bool),
+		visitedFiles:       make(map[string]bool),
+	}
+}
+
+/*
+followLink walks the target of a symlink as though it were at the
+link's path. Links which cannot be resolved are passed to the walk
+function as errors.
+*/
+func (walker *pathWalker) followLink(linkPath string, reportedPath string, walkFunc filepath.WalkFunc) error {
+	realPath, err := filepath.EvalSymlinks(linkPath)
+	if err != nil {
+		return walkFunc(reportedPath, nil, err)
+	}
+
+	info, err := os.Stat(realPath)
+	if err != nil {
+		return walkFunc(reportedPath, nil, err)
+	}
+
+	if info.IsDir() {
+		if walker.visitedDirectories[realPath] {
+			walker.log.Debugf("Not following %s to %s, which has already been walked", reportedPath, realPath)
+			return nil
+		}
+
+		return walker.walkRealPath(realPath, reportedPath, walkFunc)
+	}
+
+	if walker.visitedFiles[realPath] {
+		return nil
+	}
+
+	walker.visitedFiles[realPath] = true
+	return walkFunc(reportedPath, info, nil)
+}
+
+/*
+walk calls the walk function for every file and directory under a base
+path, as filepath.Walk does.
+*/
+func (walker *pathWalker) walk(basePath string, walkFunc filepath.WalkFunc) error {
+	if !walker.followSymlinks {
+		return filepath.Walk(basePath, walkFunc)
+	}
+
+	realPath, err := filepath.EvalSymlinks(basePath)
+	if err != nil {
+		return walkFunc(basePath, nil, err)
+	}
+
+	return walker.walkRealPath(realPath, basePath, walkFunc)
+}
+
+func (walker *pathWalker) walkRealPath(realPath string, reportedPath string, walkFunc filepath.WalkFunc) error {
+	return filepath.Walk(realPath, func(path string, info os.FileInfo, err error) error {
+		foundPath := reportedPath + path[len(realPath):]
+
+		if err != nil {
+			return walkFunc(foundPath, info, err)
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return walker.followLink(path, foundPath, walkFunc)
+		}
+
+		if info.IsDir() {
+			if walker.visitedDirectories[path] {
+				return filepath.SkipDir
+			}
+
+			result := walkFunc(foundPath, info, nil)
+			if result == nil {
+				walker.visitedDirectories[path] = true
+			}
+
+			return result
+		}
+
+		if walker.visitedFiles[path] {
+			return nil
+		}
+
+		walker.visitedFiles[path] = true
+		return walkFunc(foundPath, info, nil)
+	})
+}
@@ -0,0 +1,111 @@
+package catalog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/adampresley/minitextindexer/ignore"
+
+	"github.com/adampresley/directorywatcher"
+)
+
+/*
+pathWatcher runs a directory watcher over one of the configured paths.
+The directory watcher has no way to stop polling, so once a pathWatcher
+is stopped it ignores every change reported to it instead. A stopped
+pathWatcher is kept, and resumed if its path is configured again, so
+there is never more than one directory watcher polling a path.
+*/
+type pathWatcher struct {
+	basePath string
+	stopped  int32
+	watcher  *directorywatcher.DirectoryWatcher
+}
+
+/*
+handleChange is called by the directory watchers for every changed file
+or directory. Changed files are reindexed, deleted files and every file
+of a deleted directory are removed from the index, and changed
+directories are reconciled with the index so renamed files and
+directories are picked up. Changes in excluded directories are ignored.
+A changed ignore file rebuilds the index, as it may change which files
+are ignored anywhere below it.
+*/
+func (catalog *Catalog) handleChange(basePath, path string, info os.FileInfo) {
+	filter := catalog.getFileFilter()
+
+	if info != nil && info.IsDir() {
+		if !filter.isExcluded(basePath, path, true) {
+			catalog.syncDirectory(basePath, path)
+		}
+
+		return
+	}
+
+	if filter.ignore != nil && ignore.IsIgnoreFile(path) {
+		catalog.log.Infof("Detected change in ignore file %s. Reindexing...", path)
+		filter.ignore.Invalidate(filepath.Dir(path))
+
+		/*
+		 * Reindex in the background so the directory watcher keeps
+		 * polling, and so stopping the catalog stops the reindex
+		 */
+		go func() {
+			if err := catalog.Index(catalog.rootContext); err != nil && err != context.Canceled {
+				catalog.log.Errorf("Error reindexing after ignore file change: %s", err.Error())
+			}
+		}()
+
+		return
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		catalog.removeDeleted(path)
+		return
+	}
+
+	if !filter.isMatch(basePath, path) {
+		return
+	}
+
+	catalog.log.Infof("Detected change in path %s", path)
+
+	if err := catalog.IndexFile(path); err != nil {
+		catalog.log.Errorf("Error reindexing file %s: %s", path, err.Error())
+	}
+}
+
+func (watcher *pathWatcher) isStopped() bool {
+	return atomic.LoadInt32(&watcher.stopped) != 0
+}
+
+func (watcher *pathWatcher) resume() {
+	atomic.StoreInt32(&watcher.stopped, 0)
+}
+
+/*
+startWatcher starts watching a base path for changes.
+*/
+func (catalog *Catalog) startWatcher(basePath string) *pathWatcher {
+	result := &pathWatcher{
+		basePath: basePath,
+		watcher:  directorywatcher.NewDirectoryWatcher(basePath, catalog.log),
+	}
+
+	result.watcher.Watch(func(path string, info os.FileInfo, startTime time.Time, modificationTime time.Time) error {
+		if !result.isStopped() {
+			catalog.handleChange(basePath, path, info)
+		}
+
+		return nil
+	})
+
+	return result
+}
+
+func (watcher *pathWatcher) stop() {
+	atomic.StoreInt32(&watcher.stopped, 1)
+}
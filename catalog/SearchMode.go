@@ -0,0 +1,83 @@
+package catalog
+
+import "fmt"
+
+/*
+SearchMode describes how a search term is matched against the keys
+in the index tree.
+*/
+type SearchMode string
+
+const (
+	/*
+		SearchModeContains matches keys which contain the search term
+		anywhere. This is the default.
+	*/
+	SearchModeContains SearchMode = "contains"
+
+	/*
+		SearchModeFuzzy matches keys within a maximum Levenshtein distance
+		of the search term, closest first
+	*/
+	SearchModeFuzzy SearchMode = "fuzzy"
+
+	/*
+		SearchModeGlob matches whole keys against a glob pattern such as
+		user*Panel, ignoring case
+	*/
+	SearchModeGlob SearchMode = "glob"
+
+	/*
+		SearchModePrefix matches keys which start with the search term
+	*/
+	SearchModePrefix SearchMode = "prefix"
+
+	/*
+		SearchModeRegex matches keys against a regular expression such as
+		^btn[A-Z].*Submit$
+	*/
+	SearchModeRegex SearchMode = "regex"
+)
+
+/*
+MaxContextBytes is the most bytes of files a single search reads back
+to find the context of its matches. Matches in files beyond it are
+returned without context.
+*/
+const MaxContextBytes int64 = 32 * 1024 * 1024
+
+/*
+MaxContextFiles is the most files a single search reads back to find
+the context of its matches. Matches in files beyond it are returned
+without context.
+*/
+const MaxContextFiles = 100
+
+/*
+MaxContextLines is the most lines of context a search may ask for on
+each side of a match.
+*/
+const MaxContextLines = 50
+
+/*
+MaxFuzzyDistance is the largest edit distance a fuzzy search may ask
+for. Larger distances match nearly every short key and would visit most
+of the BK-tree.
+*/
+const MaxFuzzyDistance = 4
+
+/*
+parseSearchMode converts a string into a SearchMode. A blank string
+returns the default contains mode.
+*/
+func parseSearchMode(mode string) (SearchMode, error) {
+	switch SearchMode(mode) {
+	case "", SearchModeContains:
+		return SearchModeContains, nil
+
+	case SearchModeFuzzy, SearchModeGlob, SearchModePrefix, SearchModeRegex:
+		return SearchMode(mode), nil
+	}
+
+	return "", fmt.Errorf("unknown search mode %s", mode)
+}
@@ -0,0 +1,64 @@
+package catalog
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/adampresley/minitextindexer/config"
+	"github.com/adampresley/minitextindexer/document"
+)
+
+const skipReasonBinary = "binary content"
+
+/*
+A SkippedFile is a file which matched the configured patterns but was
+left out of the index, along with the reason why.
+*/
+type SkippedFile struct {
+	FileName string `json:"fileName"`
+	Reason   string `json:"reason"`
+	Size     int64  `json:"size"`
+}
+
+func newSkippedFileIndex(fileName string, info os.FileInfo, reason string) *fileIndex {
+	return &fileIndex{
+		fileName: fileName,
+		metadata: newFileMetadata(info),
+		skipped: &SkippedFile{
+			FileName: fileName,
+			Reason:   reason,
+			Size:     info.Size(),
+		},
+	}
+}
+
+/*
+skipReason returns why a file should be left out of the index, or a
+blank string if it should be indexed. Files larger than the maximum
+file size are skipped, as are files with binary content unless binary
+files are to be indexed. Only the start of the file is read.
+*/
+func skipReason(configuration *config.Configuration, file *document.PhysicalFile, size int64) (string, error) {
+	if maxFileSize := configuration.GetMaxFileSize(); size > maxFileSize {
+		return tooLargeReason(maxFileSize), nil
+	}
+
+	if configuration.IndexBinaryFiles {
+		return "", nil
+	}
+
+	isBinary, err := file.IsBinary()
+	if err != nil {
+		return "", err
+	}
+
+	if isBinary {
+		return skipReasonBinary, nil
+	}
+
+	return "", nil
+}
+
+func tooLargeReason(maxFileSize int64) string {
+	return fmt.Sprintf("larger than the maximum file size of %d bytes", maxFileSize)
+}
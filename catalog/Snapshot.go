@@ -0,0 +1,255 @@
+package catalog
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/adampresley/minitextindexer/config"
+	"github.com/adampresley/minitextindexer/document"
+	"github.com/adampresley/minitextindexer/fuzzy"
+	"github.com/adampresley/minitextindexer/tree"
+	"github.com/adampresley/minitextindexer/trigram"
+)
+
+/*
+fileMetadata is what the index remembers about a file on disk, so that
+unchanged files can be skipped when reindexing.
+*/
+type fileMetadata struct {
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+}
+
+func newFileMetadata(info os.FileInfo) fileMetadata {
+	return fileMetadata{
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+	}
+}
+
+/*
+fileIndex carries the scan results for a single file from the
+indexing workers to the goroutine which merges them into a snapshot.
+Files which were skipped carry the reason instead of an index, and
+files which could not be indexed carry the error. A file carried over
+from an earlier index lists the text patterns it still has to be
+scanned for, if any.
+*/
+type fileIndex struct {
+	failed       *FileError
+	fileName     string
+	index        document.DocumentIndex
+	metadata     fileMetadata
+	skipped      *SkippedFile
+	textPatterns []*config.TextPattern
+}
+
+/*
+A snapshot is a complete, consistent copy of the index: the term tree,
+the key indexes used for searching, the keys and file metadata of each
+document, and every file it knows of grouped by directory. A full reindex builds a new snapshot on the side and the
+catalog swaps it in once it is complete, so searches are never blocked
+by one. Incremental updates change the current snapshot in place while
+holding its write lock, so searches wait for each of them.
+
+The patterns field fingerprints each text pattern the snapshot was
+built with, by name. A reindex only reuses the matches of the patterns
+whose fingerprint has not changed.
+Files which were skipped or could not be indexed are kept apart from
+the indexed ones, and are always checked again by a reindex.
+*/
+type snapshot struct {
+	sync.RWMutex
+
+	directories   *directoryIndex
+	documentTerms map[string][]string
+	failed        map[string]*FileError
+	files         map[string]fileMetadata
+	fuzzyKeys     *fuzzy.BKTree
+	patterns      map[string]string
+	skipped       map[string]*SkippedFile
+	tree          *tree.Tree
+	trigrams      *trigram.Index
+}
+
+/*
+fileIndex rebuilds the index of a single document from the terms it was
+recorded under, keeping only the matches of the named text patterns.
+The documents are copies, so the result can be merged into another
+snapshot. The caller must hold the read lock.
+*/
+func (snapshot *snapshot) fileIndex(fileName string, patternNames map[string]bool) *fileIndex {
+	result := &fileIndex{
+		fileName: fileName,
+		index:    make(document.DocumentIndex),
+		metadata: snapshot.files[fileName],
+	}
+
+	for _, key := range snapshot.documentTerms[fileName] {
+		node := snapshot.tree.Find(document.NewTerm(key))
+		if node == nil {
+			continue
+		}
+
+		existingDocument := node.FindDocument(fileName)
+		if existingDocument == nil {
+			continue
+		}
+
+		documentCopy := *existingDocument
+		documentCopy.Matches = make([]*document.PatternMatch, 0, len(existingDocument.Matches))
+
+		for _, match := range existingDocument.Matches {
+			if patternNames[match.Pattern] {
+				documentCopy.Matches = append(documentCopy.Matches, match)
+			}
+		}
+
+		if len(documentCopy.Matches) > 0 {
+			result.index[key] = &documentCopy
+		}
+	}
+
+	return result
+}
+
+/*
+findTerms looks up the tree nodes for a list of keys, skipping any which
+are no longer in the tree. The caller must hold the read lock.
+*/
+func (snapshot *snapshot) findTerms(keys []string) []*tree.Node {
+	var nodes []*tree.Node
+
+	for _, key := range keys {
+		if node := snapshot.tree.Find(document.NewTerm(key)); node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+
+	return nodes
+}
+
+/*
+isUnchanged returns true if a file was indexed with the same
+modification time and size it has now. The caller must hold the read
+lock.
+*/
+func (snapshot *snapshot) isUnchanged(fileName string, info os.FileInfo) bool {
+	metadata, ok := snapshot.files[fileName]
+	return ok && metadata.Size == info.Size() && metadata.ModTime.Equal(info.ModTime())
+}
+
+/*
+merge adds the documents from a single file's index to the tree, or
+records the file as skipped or failed. It returns the number of new
+nodes created. The caller must hold the write lock, or be the only user of a
+snapshot which has not been published.
+*/
+func (snapshot *snapshot) merge(item *fileIndex) int {
+	nodeCount := 0
+	fileName := item.fileName
+
+	snapshot.directories.add(fileName)
+
+	if item.failed != nil {
+		snapshot.failed[fileName] = item.failed
+		return 0
+	}
+
+	if item.skipped != nil {
+		snapshot.skipped[fileName] = item.skipped
+		return 0
+	}
+
+	snapshot.files[fileName] = item.metadata
+
+	if _, ok := snapshot.documentTerms[fileName]; !ok {
+		snapshot.documentTerms[fileName] = make([]string, 0, len(item.index))
+	}
+
+	for key, newDocument := range item.index {
+		/*
+		 * Create a new Term and add the document to it.
+		 */
+		termToFind := document.NewTerm(key)
+		termToFind.Documents = append(termToFind.Documents, newDocument)
+
+		/*
+		 * See if this term is already in the tree. If not, just add it.
+		 * If it is there, we need to see if we have this document captured
+		 * already. If not, add it. If so, only add our matches if we don't
+		 * already have those too.
+		 */
+		existingTermNode := snapshot.tree.Find(termToFind)
+
+		if existingTermNode == nil {
+			snapshot.tree.Add(termToFind)
+			snapshot.trigrams.Add(key)
+			snapshot.fuzzyKeys.Add(key)
+			nodeCount++
+		} else {
+			existingDocument := existingTermNode.FindDocument(newDocument.DocumentName)
+
+			if existingDocument == nil {
+				existingTermNode.Value.Documents = append(existingTermNode.Value.Documents, newDocument)
+			} else {
+				currentMatches := existingDocument.Matches
+
+				for _, newMatch := range newDocument.Matches {
+					if !existingDocument.HasMatchIndex(newMatch.Location) {
+						currentMatches = append(currentMatches, newMatch)
+					}
+				}
+
+				existingDocument.Matches = currentMatches
+			}
+		}
+
+		snapshot.documentTerms[fileName] = append(snapshot.documentTerms[fileName], key)
+	}
+
+	return nodeCount
+}
+
+func newSnapshot(patterns map[string]string) *snapshot {
+	return &snapshot{
+		directories:   newDirectoryIndex(),
+		documentTerms: make(map[string][]string),
+		failed:        make(map[string]*FileError),
+		files:         make(map[string]fileMetadata),
+		fuzzyKeys:     fuzzy.NewBKTree(),
+		patterns:      patterns,
+		skipped:       make(map[string]*SkippedFile),
+		tree:          tree.NewTree(),
+		trigrams:      trigram.NewIndex(),
+	}
+}
+
+/*
+remove removes a document from every term it was recorded under,
+pruning terms left without documents, and forgets it if it was
+skipped or failed. The caller must hold the write lock.
+*/
+func (snapshot *snapshot) remove(documentName string) {
+	for _, key := range snapshot.documentTerms[documentName] {
+		term := document.NewTerm(key)
+		node := snapshot.tree.Find(term)
+
+		if node != nil {
+			node.Value.RemoveDocument(documentName)
+
+			if len(node.Value.Documents) == 0 {
+				snapshot.tree.Remove(term)
+				snapshot.trigrams.Remove(key)
+				snapshot.fuzzyKeys.Remove(key)
+			}
+		}
+	}
+
+	snapshot.directories.remove(documentName)
+	delete(snapshot.documentTerms, documentName)
+	delete(snapshot.files, documentName)
+	delete(snapshot.skipped, documentName)
+	delete(snapshot.failed, documentName)
+}
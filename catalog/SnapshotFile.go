@@ -0,0 +1,147 @@
+package catalog
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/adampresley/minitextindexer/document"
+)
+
+/*
+SnapshotFileVersion is the version of the snapshot file format written
+by SaveSnapshot. Files with any other version are not loaded.
+*/
+const SnapshotFileVersion = 5
+
+/*
+snapshotFile is the gzipped JSON document a snapshot is saved as. Terms
+are stored in lexical order along with their documents and matches.
+*/
+type snapshotFile struct {
+	Version   int                     `json:"version"`
+	CreatedAt time.Time               `json:"createdAt"`
+	Patterns  map[string]string       `json:"patterns"`
+	Files     map[string]fileMetadata `json:"files"`
+	Terms     []*document.Term        `json:"terms"`
+}
+
+/*
+LoadSnapshot replaces the index with one previously written by
+SaveSnapshot. Call Index afterwards to rescan only the files which have
+changed since the snapshot was taken. This operation locks the catalog.
+*/
+func (catalog *Catalog) LoadSnapshot(fileName string) error {
+	handle, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+
+	defer handle.Close()
+
+	reader, err := gzip.NewReader(handle)
+	if err != nil {
+		return err
+	}
+
+	contents := &snapshotFile{}
+
+	if err = json.NewDecoder(reader).Decode(contents); err != nil {
+		return err
+	}
+
+	if contents.Version != SnapshotFileVersion {
+		return fmt.Errorf("snapshot file version %d is not supported, expected %d", contents.Version, SnapshotFileVersion)
+	}
+
+	/*
+	 * Regroup the terms by document so each file is merged the same way
+	 * it would be after a scan.
+	 */
+	items := make(map[string]*fileIndex)
+
+	for fileName, metadata := range contents.Files {
+		items[fileName] = &fileIndex{
+			fileName: fileName,
+			index:    make(document.DocumentIndex),
+			metadata: metadata,
+		}
+	}
+
+	for _, term := range contents.Terms {
+		for _, termDocument := range term.Documents {
+			if item, ok := items[termDocument.DocumentName]; ok {
+				item.index[term.Key] = termDocument
+			}
+		}
+	}
+
+	loaded := newSnapshot(contents.Patterns)
+
+	for _, item := range items {
+		loaded.merge(item)
+	}
+
+	catalog.Lock()
+	catalog.current.Store(loaded)
+	catalog.Unlock()
+
+	catalog.log.Infof("Loaded index snapshot %s with %d files taken %s", fileName, len(contents.Files), contents.CreatedAt)
+	return nil
+}
+
+/*
+SaveSnapshot writes the current index to a file as gzipped JSON. The
+file is written under a temporary name and renamed into place, so an
+interrupted save never leaves a partial snapshot behind.
+*/
+func (catalog *Catalog) SaveSnapshot(fileName string) error {
+	current := catalog.getSnapshot()
+	current.RLock()
+
+	contents := &snapshotFile{
+		Version:   SnapshotFileVersion,
+		CreatedAt: time.Now(),
+		Patterns:  current.patterns,
+		Files:     make(map[string]fileMetadata, len(current.files)),
+		Terms:     make([]*document.Term, 0),
+	}
+
+	for fileName, metadata := range current.files {
+		contents.Files[fileName] = metadata
+	}
+
+	for _, node := range current.tree.SearchPrefix("") {
+		contents.Terms = append(contents.Terms, node.Value.Copy())
+	}
+
+	current.RUnlock()
+
+	tempFileName := filepath.Join(filepath.Dir(fileName), "."+filepath.Base(fileName)+".tmp")
+
+	handle, err := os.Create(tempFileName)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(handle)
+	err = json.NewEncoder(writer).Encode(contents)
+
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+
+	if closeErr := handle.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tempFileName)
+		return err
+	}
+
+	return os.Rename(tempFileName, fileName)
+}
@@ -6,36 +6,82 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
 )
 
 /*
 LoadConfiguration reads data from a Reader into a new Configuration structure.
 */
 func LoadConfiguration(reader io.Reader) (*Configuration, error) {
-	var err error
-	var contents bytes.Buffer
-	var buffer = make([]byte, 4096)
-	var bytesRead int
+	result := &Configuration{}
+
+	contents, err := readConfiguration(reader)
+	if err != nil {
+		return result, err
+	}
 
+	err = json.Unmarshal(contents, result)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+/*
+LoadConfigurationFromFile reads data from a file into a Configuration object. The
+format is chosen by the file extension: .yaml or .yml for YAML, .toml for TOML,
+and JSON for anything else. Environment variable overrides and expansion are
+then applied (see ApplyEnvironment).
+*/
+func LoadConfigurationFromFile(fileName string) (*Configuration, error) {
 	result := &Configuration{}
-	bufferedReader := bufio.NewReader(reader)
 
-	for {
-		bytesRead, err = bufferedReader.Read(buffer)
-		if err != nil && err != io.EOF {
-			return result, err
-		}
+	configFileHandle, err := os.Open(fileName)
+	if err != nil {
+		return result, err
+	}
 
-		if bytesRead == 0 {
-			break
-		}
+	defer configFileHandle.Close()
 
-		if _, err := contents.Write(buffer[:bytesRead]); err != nil {
-			return result, err
-		}
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		result, err = LoadYAMLConfiguration(configFileHandle)
+
+	case ".toml":
+		result, err = LoadTOMLConfiguration(configFileHandle)
+
+	default:
+		result, err = LoadConfiguration(configFileHandle)
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	if err = result.ApplyEnvironment(); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+/*
+LoadTOMLConfiguration reads TOML data from a Reader into a new Configuration structure.
+*/
+func LoadTOMLConfiguration(reader io.Reader) (*Configuration, error) {
+	result := &Configuration{}
+
+	contents, err := readConfiguration(reader)
+	if err != nil {
+		return result, err
 	}
 
-	err = json.Unmarshal(contents.Bytes(), result)
+	_, err = toml.Decode(string(contents), result)
 	if err != nil {
 		return result, err
 	}
@@ -44,21 +90,46 @@ func LoadConfiguration(reader io.Reader) (*Configuration, error) {
 }
 
 /*
-LoadConfigurationFromFile reads data from a file into a Configuration object. Makes use of
-LoadConfiguration().
+LoadYAMLConfiguration reads YAML data from a Reader into a new Configuration structure.
 */
-func LoadConfigurationFromFile(fileName string) (*Configuration, error) {
+func LoadYAMLConfiguration(reader io.Reader) (*Configuration, error) {
 	result := &Configuration{}
 
-	configFileHandle, err := os.Open(fileName)
+	contents, err := readConfiguration(reader)
 	if err != nil {
 		return result, err
 	}
 
-	result, err = LoadConfiguration(configFileHandle)
+	err = yaml.Unmarshal(contents, result)
 	if err != nil {
 		return result, err
 	}
 
 	return result, nil
 }
+
+func readConfiguration(reader io.Reader) ([]byte, error) {
+	var err error
+	var contents bytes.Buffer
+	var buffer = make([]byte, 4096)
+	var bytesRead int
+
+	bufferedReader := bufio.NewReader(reader)
+
+	for {
+		bytesRead, err = bufferedReader.Read(buffer)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if bytesRead == 0 {
+			break
+		}
+
+		if _, err := contents.Write(buffer[:bytesRead]); err != nil {
+			return nil, err
+		}
+	}
+
+	return contents.Bytes(), nil
+}
@@ -1,11 +1,244 @@
 package config
 
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+
+	"github.com/adampresley/minitextindexer/glob"
+)
+
+/*
+DefaultMaxFileSize is the largest file, in bytes, which is indexed when
+no maximum file size is configured.
+*/
+const DefaultMaxFileSize int64 = 10 * 1024 * 1024
+
 /*
 A Configuration structure represents the data necessary to configure
-a Mini Text Indexer instance.
+a Mini Text Indexer instance. Which files are indexed is decided by
+FilePatterns, which are plain substring matches on the path, and by the
+Include and Exclude globs, which are matched against the path relative
+to the configured path it was found under. PathRules override the globs
+for individual paths. When UseIgnoreFiles is set, files and directories
+ignored by .gitignore, .ignore, and .mtiignore files are skipped too.
+Files larger than MaxFileSize, and files with binary content unless
+IndexBinaryFiles is set, are skipped when they are indexed. Symlinked
+directories are only walked when FollowSymlinks is set. SnapshotOnExit
+saves the index to SnapshotFile when the server shuts down.
 */
 type Configuration struct {
-	FilePatterns []string       `json:"filePatterns"`
-	Paths        []string       `json:"paths"`
-	TextPatterns []*TextPattern `json:"textPatterns"`
+	Exclude          []string       `json:"exclude" yaml:"exclude" toml:"exclude"`
+	FilePatterns     []string       `json:"filePatterns" yaml:"filePatterns" toml:"filePatterns"`
+	FollowSymlinks   bool           `json:"followSymlinks" yaml:"followSymlinks" toml:"followSymlinks"`
+	Include          []string       `json:"include" yaml:"include" toml:"include"`
+	IndexBinaryFiles bool           `json:"indexBinaryFiles" yaml:"indexBinaryFiles" toml:"indexBinaryFiles"`
+	IndexWorkers     int            `json:"indexWorkers" yaml:"indexWorkers" toml:"indexWorkers"`
+	MaxFileSize      int64          `json:"maxFileSize" yaml:"maxFileSize" toml:"maxFileSize"`
+	PathRules        []*PathRule    `json:"pathRules" yaml:"pathRules" toml:"pathRules"`
+	Paths            []string       `json:"paths" yaml:"paths" toml:"paths"`
+	SnapshotFile     string         `json:"snapshotFile" yaml:"snapshotFile" toml:"snapshotFile"`
+	SnapshotOnExit   bool           `json:"snapshotOnExit" yaml:"snapshotOnExit" toml:"snapshotOnExit"`
+	TextPatterns     []*TextPattern `json:"textPatterns" yaml:"textPatterns" toml:"textPatterns"`
+	UseIgnoreFiles   bool           `json:"useIgnoreFiles" yaml:"useIgnoreFiles" toml:"useIgnoreFiles"`
+}
+
+/*
+GetIndexWorkers returns the number of workers used to read and scan
+files while indexing. When not configured this is the number of CPUs.
+*/
+func (configuration *Configuration) GetIndexWorkers() int {
+	if configuration.IndexWorkers <= 0 {
+		return runtime.NumCPU()
+	}
+
+	return configuration.IndexWorkers
+}
+
+/*
+GetMaxFileSize returns the size in bytes of the largest file which is
+indexed. When not configured this is DefaultMaxFileSize.
+*/
+func (configuration *Configuration) GetMaxFileSize() int64 {
+	if configuration.MaxFileSize <= 0 {
+		return DefaultMaxFileSize
+	}
+
+	return configuration.MaxFileSize
+}
+
+/*
+GetPathRule returns the rule for one of the configured paths, or nil if
+there is none.
+*/
+func (configuration *Configuration) GetPathRule(basePath string) *PathRule {
+	for _, pathRule := range configuration.PathRules {
+		if pathRule != nil && filepath.Clean(pathRule.Path) == filepath.Clean(basePath) {
+			return pathRule
+		}
+	}
+
+	return nil
+}
+
+/*
+Validate checks the configuration for every problem which would stop
+it from indexing correctly: missing or unreadable paths, regular
+expressions and globs which do not compile, keys and key templates
+which refer to capture groups the expression does not have, and
+duplicate pattern names. It returns nil when the configuration is
+valid, otherwise a ValidationErrors listing each problem with its
+location in the configuration.
+*/
+func (configuration *Configuration) Validate() error {
+	var result ValidationErrors
+
+	addError := func(location string, format string, args ...interface{}) {
+		result = append(result, &ValidationError{
+			Location: location,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(configuration.Paths) == 0 {
+		addError("paths", "at least one path is required")
+	}
+
+	for index, path := range configuration.Paths {
+		location := fmt.Sprintf("paths[%d]", index)
+		info, err := os.Stat(path)
+
+		if err != nil {
+			addError(location, "%s", err.Error())
+		} else if !info.IsDir() {
+			addError(location, "%s is not a directory", path)
+		}
+	}
+
+	if len(configuration.FilePatterns) == 0 && len(configuration.Include) == 0 {
+		addError("filePatterns", "at least one file pattern or include glob is required")
+	}
+
+	for index, filePattern := range configuration.FilePatterns {
+		if filePattern == "" {
+			addError(fmt.Sprintf("filePatterns[%d]", index), "file pattern is blank")
+		}
+	}
+
+	validateGlobs := func(location string, patterns []string) {
+		for index, pattern := range patterns {
+			if _, err := glob.Compile(pattern); err != nil {
+				addError(fmt.Sprintf("%s[%d]", location, index), "%s", err.Error())
+			}
+		}
+	}
+
+	validateGlobs("include", configuration.Include)
+	validateGlobs("exclude", configuration.Exclude)
+
+	rulePaths := make(map[string]int)
+
+	for index, pathRule := range configuration.PathRules {
+		location := fmt.Sprintf("pathRules[%d]", index)
+
+		if pathRule == nil {
+			addError(location, "path rule is empty")
+			continue
+		}
+
+		validateGlobs(location+".include", pathRule.Include)
+		validateGlobs(location+".exclude", pathRule.Exclude)
+
+		found := false
+
+		for _, path := range configuration.Paths {
+			if filepath.Clean(path) == filepath.Clean(pathRule.Path) {
+				found = true
+			}
+		}
+
+		if !found {
+			addError(location+".path", "%s is not one of the configured paths", pathRule.Path)
+		} else if firstIndex, ok := rulePaths[filepath.Clean(pathRule.Path)]; ok {
+			addError(location+".path", "%s already has a rule in pathRules[%d]", pathRule.Path, firstIndex)
+		} else {
+			rulePaths[filepath.Clean(pathRule.Path)] = index
+		}
+	}
+
+	if configuration.IndexWorkers < 0 {
+		addError("indexWorkers", "must not be negative")
+	}
+
+	if configuration.MaxFileSize < 0 {
+		addError("maxFileSize", "must not be negative")
+	}
+
+	if configuration.SnapshotOnExit && configuration.SnapshotFile == "" {
+		addError("snapshotOnExit", "a snapshotFile is required")
+	}
+
+	if len(configuration.TextPatterns) == 0 {
+		addError("textPatterns", "at least one text pattern is required")
+	}
+
+	names := make(map[string]int)
+
+	for index, textPattern := range configuration.TextPatterns {
+		location := fmt.Sprintf("textPatterns[%d]", index)
+
+		if textPattern == nil {
+			addError(location, "text pattern is empty")
+			continue
+		}
+
+		if textPattern.Name != "" {
+			if firstIndex, ok := names[textPattern.Name]; ok {
+				addError(location+".name", "%s is already used by textPatterns[%d]", textPattern.Name, firstIndex)
+			} else {
+				names[textPattern.Name] = index
+			}
+		}
+
+		if textPattern.Pattern == "" {
+			addError(location+".pattern", "pattern is blank")
+			continue
+		}
+
+		exp, err := regexp.Compile(textPattern.Pattern)
+		if err != nil {
+			addError(location+".pattern", "%s", err.Error())
+			continue
+		}
+
+		compiled := *textPattern
+		compiled.Regex = exp
+
+		if textPattern.KeyTemplate == "" {
+			if textPattern.Key < 0 || textPattern.Key > exp.NumSubexp() {
+				addError(location+".key", "%d is not a capture group, the pattern has %d capture group(s)", textPattern.Key, exp.NumSubexp())
+			}
+
+			continue
+		}
+
+		placeholders := keyTemplatePlaceholder.FindAllStringSubmatch(textPattern.KeyTemplate, -1)
+		if len(placeholders) == 0 {
+			addError(location+".keyTemplate", "template has no {placeholders}")
+		}
+
+		for _, placeholder := range placeholders {
+			if compiled.GetCaptureIndex(placeholder[1]) < 0 {
+				addError(location+".keyTemplate", "%s does not name a capture group", placeholder[0])
+			}
+		}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return result
 }
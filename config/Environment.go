@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+Environment variables which override settings from the configuration
+file. MTI_PATHS is a list of paths separated like PATH, and
+MTI_FILE_PATTERNS is a comma separated list.
+*/
+const (
+	EnvFilePatterns = "MTI_FILE_PATTERNS"
+	EnvIndexWorkers = "MTI_INDEX_WORKERS"
+	EnvPaths        = "MTI_PATHS"
+	EnvSnapshotFile = "MTI_SNAPSHOT_FILE"
+)
+
+/*
+ApplyEnvironment overrides settings with any MTI_ environment variables
+which are set, then expands ${VAR} references and a leading ~ in Paths,
+PathRules and SnapshotFile.
+*/
+func (configuration *Configuration) ApplyEnvironment() error {
+	if value, ok := os.LookupEnv(EnvPaths); ok {
+		configuration.Paths = filepath.SplitList(value)
+	}
+
+	if value, ok := os.LookupEnv(EnvFilePatterns); ok {
+		configuration.FilePatterns = strings.Split(value, ",")
+	}
+
+	if value, ok := os.LookupEnv(EnvIndexWorkers); ok {
+		indexWorkers, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a number: %s", EnvIndexWorkers, value)
+		}
+
+		configuration.IndexWorkers = indexWorkers
+	}
+
+	if value, ok := os.LookupEnv(EnvSnapshotFile); ok {
+		configuration.SnapshotFile = value
+	}
+
+	for index, path := range configuration.Paths {
+		configuration.Paths[index] = expandPath(path)
+	}
+
+	for _, pathRule := range configuration.PathRules {
+		if pathRule != nil {
+			pathRule.Path = expandPath(pathRule.Path)
+		}
+	}
+
+	if configuration.SnapshotFile != "" {
+		configuration.SnapshotFile = expandPath(configuration.SnapshotFile)
+	}
+
+	return nil
+}
+
+/*
+expandPath replaces ${VAR} and $VAR with the value of the environment
+variable, and a leading ~ with the user's home directory.
+*/
+func expandPath(path string) string {
+	path = os.ExpandEnv(path)
+
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		if home := os.Getenv("HOME"); home != "" {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+
+	return path
+}
@@ -0,0 +1,12 @@
+package config
+
+/*
+A PathRule sets the include and exclude globs for one of the configured
+paths. Include replaces the global include list for files under that
+path, while Exclude is added to the global exclude list.
+*/
+type PathRule struct {
+	Exclude []string `json:"exclude" yaml:"exclude" toml:"exclude"`
+	Include []string `json:"include" yaml:"include" toml:"include"`
+	Path    string   `json:"path" yaml:"path" toml:"path"`
+}
@@ -1,14 +1,125 @@
 package config
 
-import "regexp"
+import (
+	"regexp"
+	"strconv"
+)
+
+var keyTemplatePlaceholder = regexp.MustCompile(`\{(\w+)\}`)
 
 /*
 A TextPattern is a structure that describes a regular expression for
 capturing text in documents. The Key tells which capture from the
 regex capture groups that should be used as the key for tree nodes.
+Alternatively KeyTemplate builds the key from several captures, such as
+"{module}.{func}", where each placeholder is the name of a named group
+like (?P<module>...) or the index of a capture group. Name and Tags are
+optional labels used to tell matches from different patterns apart and
+to filter searches by them.
 */
 type TextPattern struct {
-	Key     int    `json:"key"`
-	Pattern string `json:"pattern"`
-	Regex   *regexp.Regexp
+	Key         int            `json:"key" yaml:"key" toml:"key"`
+	KeyTemplate string         `json:"keyTemplate" yaml:"keyTemplate" toml:"keyTemplate"`
+	Name        string         `json:"name" yaml:"name" toml:"name"`
+	Pattern     string         `json:"pattern" yaml:"pattern" toml:"pattern"`
+	Tags        []string       `json:"tags" yaml:"tags" toml:"tags"`
+	Regex       *regexp.Regexp `json:"-" yaml:"-" toml:"-"`
+}
+
+/*
+BuildKey returns the index key for a match from the set of captures
+returned by the compiled Regex. Placeholders in the key template which
+do not name a capture group are left as they are.
+*/
+func (textPattern *TextPattern) BuildKey(captures []string) string {
+	if textPattern.KeyTemplate == "" {
+		return captures[textPattern.Key]
+	}
+
+	return keyTemplatePlaceholder.ReplaceAllStringFunc(textPattern.KeyTemplate, func(placeholder string) string {
+		captureIndex := textPattern.GetCaptureIndex(placeholder[1 : len(placeholder)-1])
+
+		if captureIndex < 0 || captureIndex >= len(captures) {
+			return placeholder
+		}
+
+		return captures[captureIndex]
+	})
+}
+
+/*
+GetCaptureIndex returns the index of a capture group given either its
+name or its number. It returns -1 if there is no such group. The Regex
+must be compiled.
+*/
+func (textPattern *TextPattern) GetCaptureIndex(nameOrIndex string) int {
+	if captureIndex, err := strconv.Atoi(nameOrIndex); err == nil {
+		if captureIndex > textPattern.Regex.NumSubexp() {
+			return -1
+		}
+
+		return captureIndex
+	}
+
+	for captureIndex, name := range textPattern.Regex.SubexpNames() {
+		if name != "" && name == nameOrIndex {
+			return captureIndex
+		}
+	}
+
+	return -1
+}
+
+/*
+GetName returns the name matches from this pattern are labelled with.
+This is the configured name, or the regular expression itself when the
+pattern is not named.
+*/
+func (textPattern *TextPattern) GetName() string {
+	if textPattern.Name == "" {
+		return textPattern.Pattern
+	}
+
+	return textPattern.Name
+}
+
+/*
+GetNamedCaptures returns the values of the named capture groups in a set
+of captures, keyed by group name. It returns nil when the pattern has no
+named groups.
+*/
+func (textPattern *TextPattern) GetNamedCaptures(captures []string) map[string]string {
+	var result map[string]string
+
+	for captureIndex, name := range textPattern.Regex.SubexpNames() {
+		if name == "" || captureIndex >= len(captures) {
+			continue
+		}
+
+		if result == nil {
+			result = make(map[string]string)
+		}
+
+		result[name] = captures[captureIndex]
+	}
+
+	return result
+}
+
+/*
+HasLabel returns true if a label is this pattern's name or one of its
+tags.
+*/
+func (textPattern *TextPattern) HasLabel(label string) bool {
+	if label == textPattern.GetName() {
+		return true
+	}
+
+	for _, tag := range textPattern.Tags {
+		if label == tag {
+			return true
+		}
+	}
+
+	return false
 }
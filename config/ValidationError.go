@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+A ValidationError describes a single problem with a configuration. The
+Location is where in the configuration JSON the problem is, such as
+textPatterns[1].key.
+*/
+type ValidationError struct {
+	Location string
+	Message  string
+}
+
+func (validationError *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", validationError.Location, validationError.Message)
+}
+
+/*
+ValidationErrors is the list of every problem found when validating a
+configuration.
+*/
+type ValidationErrors []*ValidationError
+
+func (validationErrors ValidationErrors) Error() string {
+	messages := make([]string, len(validationErrors))
+
+	for index, validationError := range validationErrors {
+		messages[index] = validationError.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
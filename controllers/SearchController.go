@@ -2,6 +2,8 @@ package controllers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/adampresley/GoHttpService"
 	"github.com/adampresley/logging"
@@ -9,8 +11,32 @@ import (
 	"github.com/gorilla/context"
 )
 
+const defaultFuzzyDistance = 2
+const indexIncompleteHeader = "X-Index-Incomplete"
+const maxContextLines = catalog.MaxContextLines
+
+/*
+getListParameter returns every value of a query string parameter which
+may be repeated, comma separated, or both. Blank values are skipped.
+*/
+func getListParameter(request *http.Request, name string) []string {
+	result := make([]string, 0)
+
+	for _, value := range request.URL.Query()[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+
+	return result
+}
+
 /*
-GetSpecificTerm tries to find nodes that match a specific term
+GetSpecificTerm tries to find nodes that match a specific term. The
+X-Index-Incomplete header is set when the first full index has not
+completed yet.
 
 GET /getterm?term=[searchTerm]
 */
@@ -26,6 +52,7 @@ func GetSpecificTerm(writer http.ResponseWriter, request *http.Request) {
 	}
 
 	log.Infof("Getting term for [%s]", term)
+	setIndexIncompleteHeader(writer, catalog)
 
 	matchedTerm := catalog.FindTerm(term)
 	if matchedTerm == nil {
@@ -37,14 +64,22 @@ func GetSpecificTerm(writer http.ResponseWriter, request *http.Request) {
 }
 
 /*
-Search tries to find nodes that contain a term
+Search tries to find nodes that match a term. The optional mode
+parameter selects how the term is matched: contains (default), prefix,
+fuzzy, regex, or glob. Fuzzy searches accept an optional distance
+parameter. The optional context parameter adds that many lines before
+and after each match. The optional pattern parameter, which may be
+repeated or comma separated, keeps only matches from text patterns with
+those names or tags. The X-Index-Incomplete header is set when the
+first full index has not completed yet, so results may be missing.
 
-GET /search?term=[searchTerm]
+GET /search?term=[searchTerm]&mode=[mode]&distance=[distance]&context=[lines]&pattern=[name]
 */
 func Search(writer http.ResponseWriter, request *http.Request) {
 	log := (context.Get(request, "log")).(*logging.Logger)
 	catalog := (context.Get(request, "catalog")).(*catalog.Catalog)
 	term := request.URL.Query().Get("term")
+	mode := request.URL.Query().Get("mode")
 
 	if len(term) <= 0 {
 		log.Error("User provided blank term in /search")
@@ -52,13 +87,62 @@ func Search(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
+	distance := defaultFuzzyDistance
+
+	if distanceParam := request.URL.Query().Get("distance"); distanceParam != "" {
+		var err error
+
+		if distance, err = strconv.Atoi(distanceParam); err != nil {
+			log.Errorf("User provided invalid distance in /search: %s", distanceParam)
+			GoHttpService.BadRequest(writer, "Please provide a numeric distance")
+			return
+		}
+	}
+
+	contextLines := 0
+
+	if contextParam := request.URL.Query().Get("context"); contextParam != "" {
+		var err error
+
+		if contextLines, err = strconv.Atoi(contextParam); err != nil || contextLines < 0 || contextLines > maxContextLines {
+			log.Errorf("User provided invalid context in /search: %s", contextParam)
+			GoHttpService.BadRequest(writer, "Please provide a context between 0 and "+strconv.Itoa(maxContextLines)+" lines")
+			return
+		}
+	}
+
 	log.Infof("Searching for [%s]", term)
+	setIndexIncompleteHeader(writer, catalog)
+
+	matches, err := catalog.Search(term, mode, distance)
+	if err != nil {
+		log.Errorf("Invalid search in /search: %s", err.Error())
+		GoHttpService.BadRequest(writer, err.Error())
+		return
+	}
+
+	if patternLabels := getListParameter(request, "pattern"); len(patternLabels) > 0 {
+		matches = catalog.FilterByPattern(matches, patternLabels)
+	}
 
-	matches := catalog.Search(term)
 	if matches == nil {
 		GoHttpService.NotFound(writer, "Term "+term+" not found")
 		return
 	}
 
+	if contextLines > 0 {
+		catalog.AddMatchContext(matches, contextLines)
+	}
+
 	GoHttpService.WriteJson(writer, matches, 200)
 }
+
+/*
+setIndexIncompleteHeader flags a response as coming from an incomplete
+index, before the first full index has completed.
+*/
+func setIndexIncompleteHeader(writer http.ResponseWriter, catalog *catalog.Catalog) {
+	if !catalog.IsComplete() {
+		writer.Header().Set(indexIncompleteHeader, "true")
+	}
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/adampresley/GoHttpService"
+	"github.com/adampresley/minitextindexer/catalog"
+	"github.com/gorilla/context"
+)
+
+/*
+GetIndexErrors writes every file which could not be indexed, with the
+error and when it happened, to the response writer
+
+GET /status/errors
+*/
+func GetIndexErrors(writer http.ResponseWriter, request *http.Request) {
+	catalog := (context.Get(request, "catalog")).(*catalog.Catalog)
+	GoHttpService.WriteJson(writer, catalog.GetIndexErrors(), 200)
+}
+
+/*
+GetIndexReport writes how many files are indexed and which files were
+skipped, and why, to the response writer
+
+GET /status/report
+*/
+func GetIndexReport(writer http.ResponseWriter, request *http.Request) {
+	catalog := (context.Get(request, "catalog")).(*catalog.Catalog)
+	GoHttpService.WriteJson(writer, catalog.GetIndexReport(), 200)
+}
+
+/*
+GetIndexStatus writes the progress of the current index, or the result
+of the last one, to the response writer
+
+GET /status
+*/
+func GetIndexStatus(writer http.ResponseWriter, request *http.Request) {
+	catalog := (context.Get(request, "catalog")).(*catalog.Catalog)
+	GoHttpService.WriteJson(writer, catalog.GetStatus(), 200)
+}
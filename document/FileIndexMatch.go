@@ -5,7 +5,13 @@ A FileIndexMatch is used when scanning a file for text patterns
 */
 type FileIndexMatch struct {
 	Captures []string
+	Column   int
+	End      int
 	Key      string
+	Line     int
 	Location int
 	Match    string
+	Pattern  string
+
+	NamedCaptures map[string]string
 }
@@ -0,0 +1,12 @@
+package document
+
+/*
+A MatchContext holds the lines of a document surrounding a match. Lines
+holds the line or lines the match itself spans, with up to the requested
+number of lines before and after it. Line endings are removed.
+*/
+type MatchContext struct {
+	After  []string `json:"after"`
+	Before []string `json:"before"`
+	Lines  []string `json:"lines"`
+}
@@ -3,10 +3,22 @@ package document
 /*
 A PatternMatch is the information about a particlar match in a document.
 This includes the starting location/index of the match, the contents of the
-match, and all regex capture groups.
+match, and all regex capture groups. Location and End are byte offsets,
+with End just past the last byte of the match. Line and Column are 1-based,
+and Column counts runes rather than bytes. NamedCaptures holds the values
+of any named capture groups. Pattern is the name of the text pattern
+which produced the match. Context is only filled in when a search
+asks for the surrounding lines.
 */
 type PatternMatch struct {
-	Captures []string `json:"captures"`
-	Location int      `json:"location"`
-	Match    string   `json:"match"`
+	Captures []string      `json:"captures"`
+	Column   int           `json:"column"`
+	Context  *MatchContext `json:"context,omitempty"`
+	End      int           `json:"end"`
+	Line     int           `json:"line"`
+	Location int           `json:"location"`
+	Match    string        `json:"match"`
+	Pattern  string        `json:"pattern"`
+
+	NamedCaptures map[string]string `json:"namedCaptures,omitempty"`
 }
@@ -1,12 +1,24 @@
 package document
 
 import (
+	"bytes"
+	"io"
 	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/adampresley/minitextindexer/config"
 )
 
+/*
+BinarySniffLength is how many bytes from the start of a file are checked
+for binary content.
+*/
+const BinarySniffLength = 8000
+
 /*
 A PhysicalFile represents a file on a file system. This structure
 provides methods to read a file and perform an pattern match
@@ -19,6 +31,55 @@ type PhysicalFile struct {
 	textPatterns []*config.TextPattern
 }
 
+/*
+Context returns the lines surrounding a match, with up to contextLines
+lines before and after it. The file must have been read, and lineStarts
+must be the result of LineStarts, so it is only worked out once for
+every match in the file. If the file no longer contains the matched
+text at the match's location, because it changed after it was indexed,
+nil is returned rather than lines which do not belong to the match.
+*/
+func (file *PhysicalFile) Context(lineStarts []int, match *PatternMatch, contextLines int) *MatchContext {
+	if match.Location < 0 || match.End > len(file.Contents) || match.Location > match.End {
+		return nil
+	}
+
+	if file.Contents[match.Location:match.End] != match.Match {
+		return nil
+	}
+
+	firstLine, _ := file.position(lineStarts, match.Location)
+	lastLine := firstLine
+
+	if match.End > match.Location {
+		lastLine, _ = file.position(lineStarts, match.End-1)
+	}
+
+	beforeLine := firstLine - contextLines
+	if beforeLine < 1 {
+		beforeLine = 1
+	}
+
+	/*
+	 * A trailing line ending does not start another line
+	 */
+	lineCount := len(lineStarts)
+	if lineCount > 1 && lineStarts[lineCount-1] == len(file.Contents) {
+		lineCount--
+	}
+
+	afterLine := lastLine + contextLines
+	if afterLine > lineCount {
+		afterLine = lineCount
+	}
+
+	return &MatchContext{
+		After:  file.lines(lineStarts, lastLine+1, afterLine),
+		Before: file.lines(lineStarts, beforeLine, firstLine-1),
+		Lines:  file.lines(lineStarts, firstLine, lastLine),
+	}
+}
+
 /*
 CreateIndex creates an index of matched patterns, each with a set
 of documents attached to them.
@@ -40,8 +101,14 @@ func (file *PhysicalFile) CreateIndex() DocumentIndex {
 			case match := <-matchChannel:
 				newPatternMatch := &PatternMatch{
 					Captures: match.Captures,
+					Column:   match.Column,
+					End:      match.End,
+					Line:     match.Line,
 					Location: match.Location,
 					Match:    match.Match,
+					Pattern:  match.Pattern,
+
+					NamedCaptures: match.NamedCaptures,
 				}
 
 				if document, ok := result[match.Key]; ok {
@@ -56,7 +123,7 @@ func (file *PhysicalFile) CreateIndex() DocumentIndex {
 				waitGroup.Done()
 
 			case <-doneChan:
-				break
+				return
 			}
 		}
 	}(waitGroup)
@@ -67,7 +134,13 @@ func (file *PhysicalFile) CreateIndex() DocumentIndex {
 	 * write it to the term channel, and the match locations get
 	 * written to the locations channel.
 	 */
+	lineStarts := file.LineStarts()
+
 	for _, textPattern := range file.textPatterns {
+		if textPattern.Regex == nil {
+			continue
+		}
+
 		searchResult := textPattern.Regex.FindAllStringSubmatch(file.Contents, -1)
 		searchResultIndexes := textPattern.Regex.FindAllStringIndex(file.Contents, -1)
 
@@ -75,11 +148,20 @@ func (file *PhysicalFile) CreateIndex() DocumentIndex {
 			waitGroup.Add(len(searchResult))
 
 			for matchIndex, matchedSet := range searchResult {
+				location := searchResultIndexes[matchIndex][0]
+				line, column := file.position(lineStarts, location)
+
 				match := FileIndexMatch{
 					Captures: matchedSet,
-					Key:      matchedSet[textPattern.Key],
-					Location: searchResultIndexes[matchIndex][0],
+					Column:   column,
+					End:      searchResultIndexes[matchIndex][1],
+					Key:      textPattern.BuildKey(matchedSet),
+					Line:     line,
+					Location: location,
 					Match:    matchedSet[0],
+					Pattern:  textPattern.GetName(),
+
+					NamedCaptures: textPattern.GetNamedCaptures(matchedSet),
 				}
 
 				matchChannel <- match
@@ -93,6 +175,64 @@ func (file *PhysicalFile) CreateIndex() DocumentIndex {
 	return result
 }
 
+/*
+IsBinary reads the start of a file and reports whether it looks like
+binary content rather than text, which is when it contains a NUL byte.
+The file does not need to have been read.
+*/
+func (file *PhysicalFile) IsBinary() (bool, error) {
+	handle, err := os.Open(file.FileName)
+	if err != nil {
+		return false, err
+	}
+
+	defer handle.Close()
+
+	buffer := make([]byte, BinarySniffLength)
+
+	bytesRead, err := io.ReadFull(handle, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+
+	return bytes.IndexByte(buffer[:bytesRead], 0) >= 0, nil
+}
+
+/*
+LineStarts returns the byte offset at which each line of the contents
+begins. The file must have been read.
+*/
+func (file *PhysicalFile) LineStarts() []int {
+	result := []int{0}
+
+	for index := 0; index < len(file.Contents); index++ {
+		if file.Contents[index] == '\n' {
+			result = append(result, index+1)
+		}
+	}
+
+	return result
+}
+
+/*
+lines returns the text of the 1-based lines first through last,
+inclusive, without their line endings.
+*/
+func (file *PhysicalFile) lines(lineStarts []int, first int, last int) []string {
+	result := make([]string, 0)
+
+	for line := first; line <= last; line++ {
+		end := len(file.Contents)
+		if line < len(lineStarts) {
+			end = lineStarts[line] - 1
+		}
+
+		result = append(result, strings.TrimSuffix(file.Contents[lineStarts[line-1]:end], "\r"))
+	}
+
+	return result
+}
+
 /*
 NewPhysicalFile creates a new PhysicalFile structure. It takes a file name
 and a set of regular expressions to run against it.
@@ -104,6 +244,19 @@ func NewPhysicalFile(fileName string, textPatterns []*config.TextPattern) *Physi
 	}
 }
 
+/*
+position converts a byte offset in the contents into a 1-based line
+number and a 1-based column counted in runes.
+*/
+func (file *PhysicalFile) position(lineStarts []int, location int) (int, int) {
+	line := sort.Search(len(lineStarts), func(index int) bool {
+		return lineStarts[index] > location
+	})
+
+	column := utf8.RuneCountInString(file.Contents[lineStarts[line-1]:location]) + 1
+	return line, column
+}
+
 /*
 Read reads the actual contents of a file and puts them into
 the Contents key
@@ -23,6 +23,24 @@ func (term *Term) Compare(compareToTerm *Term) int {
 	return strings.Compare(strings.ToLower(term.Key), strings.ToLower(compareToTerm.Key))
 }
 
+/*
+Copy returns a copy of this term with its own documents. Matches are
+shared with the original, since they are never changed once recorded.
+*/
+func (term *Term) Copy() *Term {
+	result := &Term{
+		Key:       term.Key,
+		Documents: make([]*Document, len(term.Documents)),
+	}
+
+	for index, document := range term.Documents {
+		documentCopy := *document
+		result.Documents[index] = &documentCopy
+	}
+
+	return result
+}
+
 /*
 Equal returns true/false if two Term keys are the same.
 */
@@ -41,6 +59,21 @@ func NewTerm(key string) *Term {
 	}
 }
 
+/*
+RemoveDocument removes a document from this term. It returns true if
+the document was found and removed.
+*/
+func (term *Term) RemoveDocument(documentName string) bool {
+	for index, document := range term.Documents {
+		if document.DocumentName == documentName {
+			term.Documents = append(term.Documents[:index], term.Documents[index+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
 /*
 ToJSON returns a string of pretty-print JSON representing
 this term.
@@ -1,7 +1,14 @@
 package main
 
-import "flag"
+import (
+	"flag"
+	"time"
+)
+
+var configFile = flag.String("config", "./config.json", "Path to the configuration file. Files ending in .yaml, .yml or .toml are read as YAML or TOML, anything else as JSON")
 
 var ip = flag.String("ip", "localhost", "IP address/hostname to bind this service to")
 var port = flag.Int("port", 8999, "Port number to bind this service to")
 var logLevel = flag.String("loglevel", "debug", "Set minimum log level. debug or info")
+var shutdownTimeout = flag.Duration("shutdowntimeout", 10*time.Second, "How long to wait for requests in progress to finish when shutting down")
+var validate = flag.Bool("validate", false, "Validate the configuration file and exit. Exits with a non-zero status if it has errors")
@@ -0,0 +1,153 @@
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+)
+
+/*
+BKTree is a Burkhard-Keller tree of keys. Every child of a node sits at
+an exact edit distance from it, which lets a search skip whole subtrees
+using the triangle inequality. Keys are stored lower case.
+
+Removed keys are only marked as deleted. Once deleted keys outnumber
+live ones the tree is rebuilt.
+*/
+type BKTree struct {
+	root    *bkNode
+	nodes   map[string]*bkNode
+	deleted int
+}
+
+type bkNode struct {
+	children map[int]*bkNode
+	deleted  bool
+	key      string
+}
+
+/*
+Add records a key in the tree. Adding a key which is already present
+does nothing.
+*/
+func (tree *BKTree) Add(key string) {
+	key = strings.ToLower(key)
+
+	if node, ok := tree.nodes[key]; ok {
+		if node.deleted {
+			node.deleted = false
+			tree.deleted--
+		}
+
+		return
+	}
+
+	newNode := &bkNode{
+		children: make(map[int]*bkNode),
+		key:      key,
+	}
+
+	tree.nodes[key] = newNode
+
+	if tree.root == nil {
+		tree.root = newNode
+		return
+	}
+
+	currentNode := tree.root
+
+	for {
+		distance := Levenshtein(key, currentNode.key)
+		child, ok := currentNode.children[distance]
+
+		if !ok {
+			currentNode.children[distance] = newNode
+			return
+		}
+
+		currentNode = child
+	}
+}
+
+/*
+NewBKTree creates a new, empty BK-tree.
+*/
+func NewBKTree() *BKTree {
+	return &BKTree{
+		nodes: make(map[string]*bkNode),
+	}
+}
+
+/*
+Remove deletes a key from the tree.
+*/
+func (tree *BKTree) Remove(key string) {
+	key = strings.ToLower(key)
+
+	node, ok := tree.nodes[key]
+	if !ok || node.deleted {
+		return
+	}
+
+	node.deleted = true
+	tree.deleted++
+
+	if tree.deleted > len(tree.nodes)-tree.deleted {
+		tree.rebuild()
+	}
+}
+
+func (tree *BKTree) rebuild() {
+	nodes := tree.nodes
+
+	tree.root = nil
+	tree.nodes = make(map[string]*bkNode)
+	tree.deleted = 0
+
+	for key, node := range nodes {
+		if !node.deleted {
+			tree.Add(key)
+		}
+	}
+}
+
+/*
+Search returns the keys within a maximum edit distance of a search
+term, ordered by distance and then lexically.
+*/
+func (tree *BKTree) Search(searchTerm string, maxDistance int) []Match {
+	searchTerm = strings.ToLower(searchTerm)
+	results := make([]Match, 0)
+
+	if tree.root == nil {
+		return results
+	}
+
+	pending := []*bkNode{tree.root}
+
+	for len(pending) > 0 {
+		node := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		distance := Levenshtein(searchTerm, node.key)
+
+		if distance <= maxDistance && !node.deleted {
+			results = append(results, Match{Distance: distance, Key: node.key})
+		}
+
+		for childDistance, child := range node.children {
+			if childDistance >= distance-maxDistance && childDistance <= distance+maxDistance {
+				pending = append(pending, child)
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+
+		return results[i].Key < results[j].Key
+	})
+
+	return results
+}
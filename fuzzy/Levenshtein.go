@@ -0,0 +1,46 @@
+package fuzzy
+
+/*
+Levenshtein returns the number of single rune insertions, deletions and
+substitutions needed to turn one string into another.
+*/
+func Levenshtein(first string, second string) int {
+	a := []rune(first)
+	b := []rune(second)
+
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for index := range previous {
+		previous[index] = index
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = minimum(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func minimum(values ...int) int {
+	result := values[0]
+
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,10 @@
+package fuzzy
+
+/*
+A Match is a key found by a fuzzy search, along with its edit distance
+from the search term.
+*/
+type Match struct {
+	Distance int
+	Key      string
+}
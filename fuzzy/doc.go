@@ -0,0 +1,6 @@
+/*
+Package fuzzy provides approximate matching of term keys by Levenshtein
+edit distance. Keys are kept in a BK-tree so a search only compares
+against the keys which can possibly be within the requested distance.
+*/
+package fuzzy
@@ -0,0 +1,74 @@
+package glob
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*
+A Pattern is a compiled glob pattern. A * matches any run of characters
+within a single path segment, ? matches a single character, [abc] and
+[!abc] match character classes, and a backslash escapes the next
+character. A ** segment matches any number of directories, including
+none, and a trailing ** matches everything inside a directory as well
+as the directory itself.
+
+A pattern without a slash, such as *.js or node_modules, matches the
+name of a file or directory at any depth. A pattern with a slash is
+anchored to the start of the path, and a leading slash may be used to
+anchor a pattern without any other.
+*/
+type Pattern struct {
+	exp     *regexp.Regexp
+	pattern string
+}
+
+/*
+Compile parses a glob pattern.
+*/
+func Compile(pattern string) (*Pattern, error) {
+	source := filepath.ToSlash(pattern)
+	source = strings.TrimSuffix(source, "/")
+
+	if source == "" {
+		return nil, fmt.Errorf("invalid glob pattern %s: pattern is blank", pattern)
+	}
+
+	if strings.HasPrefix(source, "/") {
+		source = source[1:]
+	} else if !strings.Contains(source, "/") {
+		source = "**/" + source
+	}
+
+	expression, _, err := Translate(source, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %s: %s", pattern, err.Error())
+	}
+
+	exp, err := regexp.Compile("(?s)^" + expression + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %s: %s", pattern, err.Error())
+	}
+
+	return &Pattern{
+		exp:     exp,
+		pattern: pattern,
+	}, nil
+}
+
+/*
+Match reports whether a slash separated path, relative to the directory
+the pattern applies to, matches the pattern.
+*/
+func (pattern *Pattern) Match(path string) bool {
+	return pattern.exp.MatchString(path)
+}
+
+/*
+String returns the pattern as it was written.
+*/
+func (pattern *Pattern) String() string {
+	return pattern.pattern
+}
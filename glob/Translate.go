@@ -0,0 +1,123 @@
+package glob
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+/*
+Translate converts a glob pattern into the body of an equivalent
+regular expression, without anchors or flags. A * matches any run of
+characters, ? matches a single character, [abc] and [!abc] match
+character classes, and a backslash escapes the next character.
+
+When slashes are path separators * and ? do not match a slash, a **
+segment matches any number of directories, including none, and a
+trailing ** matches everything inside a directory as well as the
+directory itself. A ** which is not a whole segment, such as in a**b,
+is no different from *, as in git, and neither is any ** when slashes
+are not path separators.
+
+The literal text before the first wildcard is returned too, so a search
+can be narrowed to that prefix.
+*/
+func Translate(pattern string, slashIsSeparator bool) (string, string, error) {
+	var expression bytes.Buffer
+	var literalPrefix bytes.Buffer
+	inPrefix := true
+
+	anyCharacter := "."
+	if slashIsSeparator {
+		anyCharacter = "[^/]"
+	}
+
+	runes := []rune(pattern)
+
+	for index := 0; index < len(runes); index++ {
+		character := runes[index]
+
+		switch character {
+		case '*':
+			inPrefix = false
+
+			if slashIsSeparator && index+1 < len(runes) && runes[index+1] == '*' {
+				segmentStart := index == 0 || runes[index-1] == '/'
+				segmentEnd := index+2 == len(runes) || runes[index+2] == '/'
+				index++
+
+				if !segmentStart || !segmentEnd {
+					/*
+					 * As in git, a ** which is not a whole segment is a *
+					 */
+					expression.WriteString("[^/]*")
+				} else if index+1 < len(runes) && runes[index+1] == '/' {
+					index++
+					expression.WriteString("(?:.*/)?")
+				} else if index+1 == len(runes) && bytes.HasSuffix(expression.Bytes(), []byte("/")) {
+					/*
+					 * A trailing /** also matches the directory itself
+					 */
+					expression.Truncate(expression.Len() - 1)
+					expression.WriteString("(?:/.*)?")
+				} else {
+					expression.WriteString(".*")
+				}
+
+				continue
+			}
+
+			expression.WriteString(anyCharacter + "*")
+
+		case '?':
+			inPrefix = false
+			expression.WriteString(anyCharacter)
+
+		case '[':
+			inPrefix = false
+			end := index + 1
+
+			if end < len(runes) && runes[end] == '!' {
+				end++
+			}
+
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+
+			if end >= len(runes) {
+				return "", "", errors.New("unterminated character class")
+			}
+
+			class := string(runes[index+1 : end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			expression.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			index = end
+
+		case '\\':
+			if index+1 < len(runes) {
+				index++
+				character = runes[index]
+			}
+
+			fallthrough
+
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(character)))
+
+			if inPrefix {
+				literalPrefix.WriteRune(character)
+			}
+		}
+	}
+
+	return expression.String(), literalPrefix.String(), nil
+}
@@ -0,0 +1,7 @@
+/*
+Package glob matches slash separated file paths against glob patterns
+which support ** for any number of directories. It is used for the
+include and exclude rules which decide what files are indexed, and its
+translator is shared with glob searches over index keys.
+*/
+package glob
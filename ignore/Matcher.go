@@ -0,0 +1,202 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+/*
+FileNames are the ignore files read from every directory, in order of
+increasing precedence.
+*/
+var FileNames = []string{".gitignore", ".ignore", ".mtiignore"}
+
+/*
+A Matcher decides whether paths below a base path are ignored by the
+ignore files in the base path and the directories between it and the
+path. When the base path is inside a git repository, the ignore files
+in the directories from the repository root down to the base path apply
+too, as they would in git. Rules in deeper directories take precedence
+over those above them, and later rules over earlier ones. As in git, a
+file inside an ignored directory cannot be re-included, and .git
+directories are always ignored. The rules of each directory are read
+once and cached until Invalidate is called for it.
+*/
+type Matcher struct {
+	sync.Mutex
+
+	absolutePaths map[string]string
+	roots         map[string]string
+	rules         map[string][]*rule
+}
+
+/*
+getRoot returns the absolute form of a base path, and the directory
+whose ignore files are the first to apply to it. This is the root of
+the git repository the base path is in, which is the nearest directory
+holding a .git directory or file, or the base path itself when it is
+not in a repository.
+*/
+func (matcher *Matcher) getRoot(basePath string) (string, string) {
+	matcher.Lock()
+	defer matcher.Unlock()
+
+	if absolutePath, ok := matcher.absolutePaths[basePath]; ok {
+		return absolutePath, matcher.roots[basePath]
+	}
+
+	absolutePath := toAbsolute(basePath)
+	root := absolutePath
+
+	for directory := absolutePath; ; directory = filepath.Dir(directory) {
+		if _, err := os.Stat(filepath.Join(directory, ".git")); err == nil {
+			root = directory
+			break
+		}
+
+		if filepath.Dir(directory) == directory {
+			break
+		}
+	}
+
+	matcher.absolutePaths[basePath] = absolutePath
+	matcher.roots[basePath] = root
+	return absolutePath, root
+}
+
+func (matcher *Matcher) getRules(directory string) []*rule {
+	matcher.Lock()
+	defer matcher.Unlock()
+
+	if result, ok := matcher.rules[directory]; ok {
+		return result
+	}
+
+	var result []*rule
+
+	for _, fileName := range FileNames {
+		fileRules, _ := readRules(filepath.Join(directory, fileName))
+		result = append(result, fileRules...)
+	}
+
+	matcher.rules[directory] = result
+	return result
+}
+
+/*
+Invalidate forgets the cached rules of a directory, so its ignore files
+are read again the next time they are needed.
+*/
+func (matcher *Matcher) Invalidate(directory string) {
+	matcher.Lock()
+	defer matcher.Unlock()
+
+	delete(matcher.rules, toAbsolute(directory))
+}
+
+/*
+IsIgnored reports whether a file or directory below a base path is
+ignored, either itself or because a directory above it is.
+*/
+func (matcher *Matcher) IsIgnored(basePath, path string, isDirectory bool) bool {
+	basePath = filepath.Clean(basePath)
+
+	relativePath, err := filepath.Rel(basePath, path)
+	if err != nil || relativePath == "." || strings.HasPrefix(relativePath, "..") {
+		return false
+	}
+
+	parts := strings.Split(filepath.ToSlash(relativePath), "/")
+	current := basePath
+
+	for index, part := range parts {
+		current = filepath.Join(current, part)
+
+		if matcher.isMatch(basePath, current, isDirectory || index < len(parts)-1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+IsIgnoreFile reports whether a path is one of the ignore files.
+*/
+func IsIgnoreFile(path string) bool {
+	name := filepath.Base(path)
+
+	for _, fileName := range FileNames {
+		if name == fileName {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+isMatch applies the rules of every directory from the root of the base
+path down to the parent of a path. The last rule which matches decides.
+Paths are made absolute first, as the root may be above the base path.
+*/
+func (matcher *Matcher) isMatch(basePath, path string, isDirectory bool) bool {
+	if isDirectory && filepath.Base(path) == ".git" {
+		return true
+	}
+
+	absoluteBasePath, directory := matcher.getRoot(basePath)
+	pathInBase, _ := filepath.Rel(basePath, path)
+	path = filepath.Join(absoluteBasePath, pathInBase)
+
+	result := false
+	parent := filepath.Dir(path)
+
+	for {
+		relativePath, _ := filepath.Rel(directory, path)
+		relativePath = filepath.ToSlash(relativePath)
+
+		for _, directoryRule := range matcher.getRules(directory) {
+			if directoryRule.directoryOnly && !isDirectory {
+				continue
+			}
+
+			if directoryRule.pattern.Match(relativePath) {
+				result = !directoryRule.negate
+			}
+		}
+
+		if directory == parent {
+			return result
+		}
+
+		next, _ := filepath.Rel(directory, parent)
+		directory = filepath.Join(directory, strings.SplitN(filepath.ToSlash(next), "/", 2)[0])
+	}
+}
+
+/*
+NewMatcher creates a new matcher with an empty cache.
+*/
+func NewMatcher() *Matcher {
+	return &Matcher{
+		absolutePaths: make(map[string]string),
+		roots:         make(map[string]string),
+		rules:         make(map[string][]*rule),
+	}
+}
+
+/*
+toAbsolute returns the absolute, cleaned form of a path. Rules are
+cached by absolute directory so the same directory is only read once,
+however it is reached.
+*/
+func toAbsolute(path string) string {
+	if result, err := filepath.Abs(path); err == nil {
+		return result
+	}
+
+	return filepath.Clean(path)
+}
@@ -0,0 +1,163 @@
+package ignore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type ignoreCase struct {
+	path        string
+	isDirectory bool
+	expected    bool
+}
+
+/*
+newTestTree creates a temporary git repository holding the given ignore
+files, keyed by their slash separated path, and returns its directory.
+The caller removes the directory.
+*/
+func newTestTree(t *testing.T, ignoreFiles map[string]string) string {
+	directory, err := ioutil.TempDir("", "ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Mkdir(filepath.Join(directory, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range ignoreFiles {
+		fileName := filepath.Join(directory, filepath.FromSlash(name))
+
+		if err = os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = ioutil.WriteFile(fileName, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return directory
+}
+
+func checkIgnored(t *testing.T, basePath string, cases []ignoreCase) {
+	matcher := NewMatcher()
+
+	for _, testCase := range cases {
+		path := filepath.Join(basePath, filepath.FromSlash(testCase.path))
+
+		if actual := matcher.IsIgnored(basePath, path, testCase.isDirectory); actual != testCase.expected {
+			t.Errorf("IsIgnored(%s) = %v, expected %v", testCase.path, actual, testCase.expected)
+		}
+	}
+}
+
+func TestAnchoring(t *testing.T) {
+	directory := newTestTree(t, map[string]string{
+		".gitignore": "/top.txt\ndocs/*.md\n*.log\n",
+	})
+	defer os.RemoveAll(directory)
+
+	checkIgnored(t, directory, []ignoreCase{
+		{"top.txt", false, true},
+		{"sub/top.txt", false, false},
+		{"docs/readme.md", false, true},
+		{"sub/docs/readme.md", false, false},
+		{"docs/sub/readme.md", false, false},
+		{"a.log", false, true},
+		{"sub/deep/a.log", false, true},
+		{"a.js", false, false},
+	})
+}
+
+func TestDirectoryRules(t *testing.T) {
+	directory := newTestTree(t, map[string]string{
+		".gitignore": "out/\n",
+	})
+	defer os.RemoveAll(directory)
+
+	checkIgnored(t, directory, []ignoreCase{
+		{"out", true, true},
+		{"out/a.js", false, true},
+		{"sub/out/a.js", false, true},
+		{"out", false, false},
+		{".git", true, true},
+		{".git/config", false, true},
+	})
+}
+
+func TestNegation(t *testing.T) {
+	directory := newTestTree(t, map[string]string{
+		".gitignore": "*.log\n!keep.log\nbuild/\n!build/keep.js\n",
+	})
+	defer os.RemoveAll(directory)
+
+	checkIgnored(t, directory, []ignoreCase{
+		{"a.log", false, true},
+		{"keep.log", false, false},
+		{"sub/keep.log", false, false},
+		{"build/keep.js", false, true},
+		{"build/a.js", false, true},
+	})
+}
+
+func TestPrecedence(t *testing.T) {
+	directory := newTestTree(t, map[string]string{
+		".gitignore":     "*.tmp\nsecret.txt\n",
+		".ignore":        "!a.tmp\n",
+		".mtiignore":     "b.tmp\n!secret.txt\n",
+		"sub/.gitignore": "!c.tmp\nlocal.txt\n",
+	})
+	defer os.RemoveAll(directory)
+
+	checkIgnored(t, directory, []ignoreCase{
+		{"a.tmp", false, false},
+		{"b.tmp", false, true},
+		{"c.tmp", false, true},
+		{"sub/c.tmp", false, false},
+		{"secret.txt", false, false},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+	})
+}
+
+func TestDoubleStar(t *testing.T) {
+	directory := newTestTree(t, map[string]string{
+		".gitignore": "a**b\nfoo**/bar\n**/logs\ncache/**\nx/**/y\n",
+	})
+	defer os.RemoveAll(directory)
+
+	checkIgnored(t, directory, []ignoreCase{
+		{"ab", false, true},
+		{"axxb", false, true},
+		{"a/x/b", false, false},
+		{"foox/bar", false, true},
+		{"foobar", false, false},
+		{"foo/x/bar", false, false},
+		{"logs", true, true},
+		{"deep/down/logs", true, true},
+		{"cache", true, true},
+		{"cache/a/b.js", false, true},
+		{"x/y", false, true},
+		{"x/a/b/y", false, true},
+		{"xy", false, false},
+	})
+}
+
+func TestRepositoryRoot(t *testing.T) {
+	directory := newTestTree(t, map[string]string{
+		".gitignore":     "*.log\n/src/secret.txt\n",
+		"src/.gitignore": "!keep.log\n",
+	})
+	defer os.RemoveAll(directory)
+
+	checkIgnored(t, filepath.Join(directory, "src"), []ignoreCase{
+		{"a.log", false, true},
+		{"keep.log", false, false},
+		{"secret.txt", false, true},
+		{"a.js", false, false},
+	})
+}
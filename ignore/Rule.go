@@ -0,0 +1,99 @@
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/adampresley/minitextindexer/glob"
+)
+
+/*
+A rule is a single line of an ignore file. A rule ending in a slash only
+matches directories, and one starting with ! re-includes what an
+earlier rule ignored.
+*/
+type rule struct {
+	directoryOnly bool
+	negate        bool
+	pattern       *glob.Pattern
+}
+
+/*
+parseRule parses a line of an ignore file. Blank lines, comments, and
+patterns which do not compile return nil.
+*/
+func parseRule(line string) *rule {
+	line = trimTrailingSpaces(line)
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	result := &rule{}
+
+	if strings.HasPrefix(line, "!") {
+		result.negate = true
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		result.directoryOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if line == "" {
+		return nil
+	}
+
+	pattern, err := glob.Compile(line)
+	if err != nil {
+		return nil
+	}
+
+	result.pattern = pattern
+	return result
+}
+
+/*
+readRules reads every rule from an ignore file. A file which does not
+exist has no rules.
+*/
+func readRules(fileName string) ([]*rule, error) {
+	var result []*rule
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if parsed := parseRule(scanner.Text()); parsed != nil {
+			result = append(result, parsed)
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+/*
+trimTrailingSpaces removes trailing spaces from a line unless they are
+escaped with a backslash.
+*/
+func trimTrailingSpaces(line string) string {
+	line = strings.TrimRight(line, "\r")
+
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+
+	return line
+}
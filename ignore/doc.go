@@ -0,0 +1,6 @@
+/*
+Package ignore reads .gitignore style files and decides which files and
+directories they ignore. Ignore files apply to the directory they are
+in and everything below it, with the same pattern rules as git.
+*/
+package ignore
@@ -1,8 +1,10 @@
 package listener
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/adampresley/minitextindexer/middleware"
 
@@ -14,7 +16,7 @@ import (
 HTTPListenerService is a structure which provides an HTTP listener to service
 requests. This structure offers methods to add routes and middlewares. Typical
 usage would first call NewHTTPListenerService(), add routes, then call
-StartHTTPListener.
+StartHTTPListener, and call Shutdown to stop.
 */
 type HTTPListenerService struct {
 	Address string
@@ -23,6 +25,10 @@ type HTTPListenerService struct {
 
 	Router                 *mux.Router
 	BaseMiddlewareHandlers alice.Chain
+
+	server     *http.Server
+	serverLock sync.Mutex
+	shutDown   bool
 }
 
 /*
@@ -90,14 +96,46 @@ func (service *HTTPListenerService) AddStaticRoute(pathPrefix string, directory
 }
 
 /*
-StartHTTPListener starts the HTTP listener and servicing requests.
+Shutdown stops the HTTP listener from accepting new connections and
+waits for requests in progress to finish, or for the context to be
+done, whichever comes first. If the listener has not been started it
+will not start.
+*/
+func (service *HTTPListenerService) Shutdown(ctx context.Context) error {
+	service.serverLock.Lock()
+	defer service.serverLock.Unlock()
+
+	service.shutDown = true
+
+	if service.server == nil {
+		return nil
+	}
+
+	return service.server.Shutdown(ctx)
+}
+
+/*
+StartHTTPListener starts the HTTP listener and services requests until
+it is shut down. It always returns an error: http.ErrServerClosed once
+Shutdown has been called, otherwise the reason the listener stopped,
+such as the address already being in use.
 */
 func (service *HTTPListenerService) StartHTTPListener() error {
+	service.serverLock.Lock()
+
+	if service.shutDown {
+		service.serverLock.Unlock()
+		return http.ErrServerClosed
+	}
+
 	listener := &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", service.Address, service.Port),
 		Handler: alice.New().Then(service.Router),
 	}
 
+	service.server = listener
+	service.serverLock.Unlock()
+
 	service.Context.Log.Info("HTTP listener started on", service.Address, ":", service.Port)
 	return listener.ListenAndServe()
 }
@@ -4,7 +4,6 @@ import (
 	"net/http"
 
 	"github.com/adampresley/minitextindexer/catalog"
-	"github.com/adampresley/minitextindexer/config"
 
 	"github.com/adampresley/logging"
 	"github.com/gorilla/context"
@@ -18,7 +17,6 @@ handlers.
 */
 type AppContext struct {
 	Catalog *catalog.Catalog
-	Config  *config.Configuration
 	Log     *logging.Logger
 	Version string
 }
@@ -31,7 +29,7 @@ Context which comes across in the request.
 func (ctx *AppContext) StartAppContext(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		context.Set(request, "catalog", ctx.Catalog)
-		context.Set(request, "config", ctx.Config)
+		context.Set(request, "config", ctx.Catalog.GetConfig())
 		context.Set(request, "log", ctx.Log)
 		context.Set(request, "version", ctx.Version)
 
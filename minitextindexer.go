@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 	"os/signal"
@@ -27,23 +28,55 @@ func main() {
 	 */
 	log.Info("Loading configuration file...")
 
-	configuration, err := config.LoadConfigurationFromFile("./config.json")
+	configFileName := *configFile
+
+	configuration, err := config.LoadConfigurationFromFile(configFileName)
 	if err != nil {
-		log.Fatalf("There was an error loading the configuration file config.json: %s", err.Error())
+		log.Fatalf("There was an error loading the configuration file %s: %s", configFileName, err.Error())
+		os.Exit(1)
+	}
+
+	if err = configuration.Validate(); err != nil {
+		logValidationErrors(log, configFileName, err)
+		log.Fatalf("The configuration file %s is invalid", configFileName)
 		os.Exit(1)
 	}
 
+	if *validate {
+		log.Infof("The configuration file %s is valid", configFileName)
+		os.Exit(0)
+	}
+
 	/*
 	 * Setup shutdown channel, application context and HTTP listener. Start serving
 	 */
 	log.Info("Creating index...")
 
 	catalog := catalog.NewCatalog(log, configuration)
-	go catalog.Index()
+
+	if configuration.SnapshotFile != "" {
+		if err = catalog.LoadSnapshot(configuration.SnapshotFile); err != nil && !os.IsNotExist(err) {
+			log.Errorf("There was an error loading the index snapshot %s. A full index will be built: %s", configuration.SnapshotFile, err.Error())
+		}
+	}
+
+	/*
+	 * Indexing and configuration reloads stop when this context is
+	 * cancelled on shutdown
+	 */
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		if err := catalog.Index(ctx); err != nil && err != context.Canceled {
+			log.Errorf("There was an error indexing: %s", err.Error())
+		}
+	}()
+
+	go watchConfiguration(ctx, log, catalog, configFileName)
 
 	appContext := &middleware.AppContext{
 		Catalog: catalog,
-		Config:  configuration,
 		Log:     log,
 		Version: VERSION,
 	}
@@ -54,16 +87,57 @@ func main() {
 	setupMiddleware(httpListener, appContext)
 	setupRoutes(httpListener, appContext)
 
-	go httpListener.StartHTTPListener()
+	listenErrors := make(chan error, 1)
+
+	go func() {
+		listenErrors <- httpListener.StartHTTPListener()
+	}()
 
 	/*
 	 * Block this thread until we receive SIGINT or
-	 * SIGTERM
+	 * SIGTERM, or the HTTP listener fails
 	 */
-	doneChannel := make(chan os.Signal)
+	exitCode := 0
+
+	doneChannel := make(chan os.Signal, 1)
 	signal.Notify(doneChannel, syscall.SIGINT, syscall.SIGTERM)
-	log.Info(<-doneChannel)
+
+	select {
+	case receivedSignal := <-doneChannel:
+		log.Info(receivedSignal)
+
+	case err = <-listenErrors:
+		log.Errorf("The HTTP listener stopped: %s", err.Error())
+		exitCode = 1
+	}
+
+	/*
+	 * Stop accepting requests and let those in progress finish, then
+	 * stop the watchers and any index still running
+	 */
+	log.Info("Shutting down...")
+	cancel()
+
+	shutdownContext, cancelShutdown := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancelShutdown()
+
+	if err = httpListener.Shutdown(shutdownContext); err != nil {
+		log.Errorf("There was an error shutting down the HTTP listener: %s", err.Error())
+	}
+
+	catalog.Stop()
+
+	shutdownConfig := catalog.GetConfig()
+
+	if shutdownConfig.SnapshotOnExit && shutdownConfig.SnapshotFile != "" {
+		if !catalog.IsComplete() {
+			log.Infof("The index is incomplete and is not saved to %s", shutdownConfig.SnapshotFile)
+		} else if err = catalog.SaveSnapshot(shutdownConfig.SnapshotFile); err != nil {
+			log.Errorf("There was an error saving the index snapshot %s: %s", shutdownConfig.SnapshotFile, err.Error())
+			exitCode = 1
+		}
+	}
 
 	log.Info("Shut down.")
-	os.Exit(0)
+	os.Exit(exitCode)
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/adampresley/minitextindexer/catalog"
+	"github.com/adampresley/minitextindexer/config"
+
+	"github.com/adampresley/logging"
+)
+
+/*
+How often the configuration file is checked for changes
+*/
+const configPollInterval = 2 * time.Second
+
+/*
+logValidationErrors writes each problem found in a configuration file
+to the log.
+*/
+func logValidationErrors(log *logging.Logger, fileName string, err error) {
+	validationErrors, ok := err.(config.ValidationErrors)
+	if !ok {
+		log.Errorf("Invalid configuration in %s: %s", fileName, err.Error())
+		return
+	}
+
+	for _, validationError := range validationErrors {
+		log.Errorf("Invalid configuration in %s at %s", fileName, validationError.Error())
+	}
+}
+
+/*
+reloadConfiguration loads and validates the configuration file, then
+hands it to the catalog. If the file can't be loaded or is invalid the
+catalog keeps its current configuration.
+*/
+func reloadConfiguration(ctx context.Context, log *logging.Logger, catalog *catalog.Catalog, fileName string) {
+	log.Infof("Reloading configuration file %s...", fileName)
+
+	configuration, err := config.LoadConfigurationFromFile(fileName)
+	if err != nil {
+		log.Errorf("There was an error loading the configuration file %s. Keeping the current configuration: %s", fileName, err.Error())
+		return
+	}
+
+	if err = configuration.Validate(); err != nil {
+		logValidationErrors(log, fileName, err)
+		log.Error("Keeping the current configuration")
+		return
+	}
+
+	if err = catalog.Reload(ctx, configuration); err != nil && err != context.Canceled {
+		log.Errorf("There was an error reindexing after reloading the configuration: %s", err.Error())
+	}
+}
+
+/*
+watchConfiguration reloads the configuration file whenever the process
+receives SIGHUP or the file's modification time changes. Each reload
+runs in its own goroutine, so a later reload can stop the reindex of an
+earlier one which is still running. It blocks until the context is
+cancelled, so run it in its own goroutine.
+*/
+func watchConfiguration(ctx context.Context, log *logging.Logger, catalog *catalog.Catalog, fileName string) {
+	hangupChannel := make(chan os.Signal, 1)
+	signal.Notify(hangupChannel, syscall.SIGHUP)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	lastModTime := getModTime(fileName)
+
+	for {
+		select {
+		case <-ctx.Done():
+			signal.Stop(hangupChannel)
+			return
+
+		case <-hangupChannel:
+			log.Info("Received SIGHUP")
+			lastModTime = getModTime(fileName)
+			go reloadConfiguration(ctx, log, catalog, fileName)
+
+		case <-ticker.C:
+			modTime := getModTime(fileName)
+
+			if !modTime.IsZero() && !modTime.Equal(lastModTime) {
+				lastModTime = modTime
+				go reloadConfiguration(ctx, log, catalog, fileName)
+			}
+		}
+	}
+}
+
+func getModTime(fileName string) time.Time {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
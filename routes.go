@@ -13,5 +13,8 @@ func setupRoutes(httpListener *listener.HTTPListenerService, appContext *middlew
 	httpListener.
 		AddRoute("/getterm", controllers.GetSpecificTerm, "GET", "OPTIONS").
 		AddRoute("/search", controllers.Search, "GET", "OPTIONS").
+		AddRoute("/status", controllers.GetIndexStatus, "GET").
+		AddRoute("/status/errors", controllers.GetIndexErrors, "GET").
+		AddRoute("/status/report", controllers.GetIndexReport, "GET").
 		AddRoute("/version", controllers.GetVersion, "GET")
 }
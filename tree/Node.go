@@ -11,6 +11,8 @@ type Node struct {
 	Left   *Node `json:"left"`
 	Right  *Node `json:"right"`
 	Parent *Node `json:"-"`
+
+	height int
 }
 
 /*
@@ -27,6 +29,14 @@ func (node *Node) FindDocument(documentName string) *document.Document {
 	return nil
 }
 
+func (node *Node) getHeight() int {
+	if node == nil {
+		return 0
+	}
+
+	return node.height
+}
+
 /*
 NewNode creates a new tree node with a specified value.
 */
@@ -35,3 +45,26 @@ func NewNode(term *document.Term) *Node {
 		Value: term,
 	}
 }
+
+/*
+update recalculates this node's height from its children and points
+the children back at this node.
+*/
+func (node *Node) update() {
+	leftHeight := node.Left.getHeight()
+	rightHeight := node.Right.getHeight()
+
+	if leftHeight > rightHeight {
+		node.height = leftHeight + 1
+	} else {
+		node.height = rightHeight + 1
+	}
+
+	if node.Left != nil {
+		node.Left.Parent = node
+	}
+
+	if node.Right != nil {
+		node.Right.Parent = node
+	}
+}
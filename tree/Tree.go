@@ -3,44 +3,96 @@ package tree
 import (
 	"encoding/json"
 	"strings"
-	"sync"
 
 	"github.com/adampresley/minitextindexer/document"
 )
 
 /*
-Tree is a tree structure which is the index of our
-text files and their matching terms/values
+Tree is a self-balancing (AVL) binary search tree which is the index
+of our text files and their matching terms/values
 */
 type Tree struct {
 	Root *Node `json:"root"`
+
+	count int
 }
 
 /*
-Add creates a new tree node and inserts it into the tree.
+Add creates a new tree node and inserts it into the tree, rebalancing
+as needed. If the term is already in the tree nil is returned.
 */
 func (tree *Tree) Add(term *document.Term) *Node {
 	newNode := NewNode(term)
-	parent := tree.findLast(term)
+	root, added := insert(tree.Root, newNode)
 
-	if parent == nil {
-		tree.Root = newNode
-	} else {
-		compare := term.Compare(parent.Value)
+	tree.Root = root
+	tree.Root.Parent = nil
 
-		if compare < 0 {
-			parent.Left = newNode
-		} else if compare > 0 {
-			parent.Right = newNode
-		} else {
-			// value is already in the tree
-			return nil
+	if !added {
+		return nil
+	}
+
+	tree.count++
+	return newNode
+}
+
+/*
+balance restores the AVL invariant on a node whose subtrees differ in
+height by at most two. The new root of the subtree is returned.
+*/
+func balance(node *Node) *Node {
+	node.update()
+	balanceFactor := node.Left.getHeight() - node.Right.getHeight()
+
+	if balanceFactor > 1 {
+		if node.Left.Left.getHeight() < node.Left.Right.getHeight() {
+			node.Left = rotateLeft(node.Left)
 		}
 
-		newNode.Parent = parent
+		return rotateRight(node)
 	}
 
-	return newNode
+	if balanceFactor < -1 {
+		if node.Right.Right.getHeight() < node.Right.Left.getHeight() {
+			node.Right = rotateRight(node.Right)
+		}
+
+		return rotateLeft(node)
+	}
+
+	return node
+}
+
+/*
+Count returns the number of terms in the tree.
+*/
+func (tree *Tree) Count() int {
+	return tree.count
+}
+
+/*
+Filter returns, in lexical order, the nodes whose keys are accepted by
+a match function. Every node is visited.
+*/
+func (tree *Tree) Filter(match func(key string) bool) []*Node {
+	var results []*Node
+	filter(tree.Root, match, &results)
+
+	return results
+}
+
+func filter(node *Node, match func(key string) bool, results *[]*Node) {
+	if node == nil {
+		return
+	}
+
+	filter(node.Left, match, results)
+
+	if match(node.Value.Key) {
+		*results = append(*results, node)
+	}
+
+	filter(node.Right, match, results)
 }
 
 /*
@@ -69,84 +121,171 @@ func (tree *Tree) Find(term *document.Term) *Node {
 	return nil
 }
 
+func insert(node *Node, newNode *Node) (*Node, bool) {
+	if node == nil {
+		newNode.update()
+		return newNode, true
+	}
+
+	var added bool
+	compare := newNode.Value.Compare(node.Value)
+
+	if compare < 0 {
+		node.Left, added = insert(node.Left, newNode)
+	} else if compare > 0 {
+		node.Right, added = insert(node.Right, newNode)
+	} else {
+		// value is already in the tree
+		return node, false
+	}
+
+	return balance(node), added
+}
+
 /*
-Traverse the tree until we find a suitable node that has a lexical
-ordering just before the specified term.
+NewTree creates a new, empty tree
 */
-func (tree *Tree) findLast(term *document.Term) *Node {
-	currentNode := tree.Root
-	var previousNode *Node
+func NewTree() *Tree {
+	return &Tree{}
+}
 
-	for {
-		if currentNode == nil {
-			break
-		}
+/*
+Remove deletes the node holding a specific term from the tree, rebalancing
+as needed. The removed node is returned, or nil if the term is not in
+the tree.
+*/
+func (tree *Tree) Remove(term *document.Term) *Node {
+	root, removed := remove(tree.Root, term)
 
-		previousNode = currentNode
-		compare := term.Compare(currentNode.Value)
+	tree.Root = root
+	if tree.Root != nil {
+		tree.Root.Parent = nil
+	}
 
-		if compare < 0 {
-			currentNode = currentNode.Left
-		} else if compare > 0 {
-			currentNode = currentNode.Right
-		} else {
-			return currentNode
-		}
+	if removed != nil {
+		tree.count--
 	}
 
-	return previousNode
+	return removed
 }
 
-func inOrder(node *Node, nodeChannel chan *Node, searchTerm string, waitGroup *sync.WaitGroup) {
+func remove(node *Node, term *document.Term) (*Node, *Node) {
 	if node == nil {
-		return
+		return nil, nil
+	}
+
+	var removed *Node
+	compare := term.Compare(node.Value)
+
+	if compare < 0 {
+		node.Left, removed = remove(node.Left, term)
+	} else if compare > 0 {
+		node.Right, removed = remove(node.Right, term)
+	} else {
+		if node.Left == nil || node.Right == nil {
+			child := node.Left
+			if child == nil {
+				child = node.Right
+			}
+
+			node.Left = nil
+			node.Right = nil
+			node.Parent = nil
+			return child, node
+		}
+
+		/*
+		 * A node with two children takes the value of its in-order successor,
+		 * which is then unlinked from the right subtree instead.
+		 */
+		var successor *Node
+		node.Right, successor = removeMin(node.Right)
+		node.Value, successor.Value = successor.Value, node.Value
+		removed = successor
 	}
 
-	waitGroup.Add(1)
-	nodeChannel <- node
-	inOrder(node.Left, nodeChannel, searchTerm, waitGroup)
-	inOrder(node.Right, nodeChannel, searchTerm, waitGroup)
+	if removed == nil {
+		return node, nil
+	}
+
+	return balance(node), removed
 }
 
-/*
-NewTree creates a new tree with a specific root node
-*/
-func NewTree(rootTerm *document.Term) *Tree {
-	return &Tree{
-		Root: NewNode(rootTerm),
+func removeMin(node *Node) (*Node, *Node) {
+	if node.Left == nil {
+		right := node.Right
+		node.Right = nil
+		node.Parent = nil
+		return right, node
 	}
+
+	var min *Node
+	node.Left, min = removeMin(node.Left)
+	return balance(node), min
+}
+
+func rotateLeft(node *Node) *Node {
+	pivot := node.Right
+	node.Right = pivot.Left
+	pivot.Left = node
+
+	node.update()
+	pivot.update()
+	return pivot
+}
+
+func rotateRight(node *Node) *Node {
+	pivot := node.Left
+	node.Left = pivot.Right
+	pivot.Right = node
+
+	node.update()
+	pivot.update()
+	return pivot
 }
 
 /*
-Search returns a set of nodes who's values contain a search term
+Search returns, in lexical order, the nodes whose keys contain a search
+term, ignoring case. Every node is visited.
 */
 func (tree *Tree) Search(searchTerm string) []*Node {
-	nodeChannel := make(chan *Node, 100)
-	doneChannel := make(chan bool)
-	waitGroup := &sync.WaitGroup{}
+	searchTerm = strings.ToLower(searchTerm)
+
+	return tree.Filter(func(key string) bool {
+		return strings.Contains(strings.ToLower(key), searchTerm)
+	})
+}
+
+/*
+SearchPrefix returns, in lexical order, the nodes whose keys start with
+a prefix. Only the subtrees that can hold such keys are visited.
+*/
+func (tree *Tree) SearchPrefix(prefix string) []*Node {
 	var results []*Node
+	prefixRange(tree.Root, strings.ToLower(prefix), &results)
 
-	go func(nodeChannel chan *Node) {
-		for {
-			select {
-			case node := <-nodeChannel:
-				if strings.Contains(strings.ToLower(node.Value.Key), strings.ToLower(searchTerm)) {
-					results = append(results, node)
-				}
+	return results
+}
 
-				waitGroup.Done()
+func prefixRange(node *Node, prefix string, results *[]*Node) {
+	if node == nil {
+		return
+	}
 
-			case <-doneChannel:
-				break
-			}
-		}
-	}(nodeChannel)
+	key := strings.ToLower(node.Value.Key)
+	hasPrefix := strings.HasPrefix(key, prefix)
 
-	inOrder(tree.Root, nodeChannel, searchTerm, waitGroup)
-	waitGroup.Wait()
-	doneChannel <- true
+	if key >= prefix {
+		prefixRange(node.Left, prefix, results)
+	}
 
-	return results
+	if hasPrefix {
+		*results = append(*results, node)
+	}
+
+	if key < prefix || hasPrefix {
+		prefixRange(node.Right, prefix, results)
+	}
 }
 
 /*
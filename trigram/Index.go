@@ -0,0 +1,163 @@
+package trigram
+
+import (
+	"sort"
+	"strings"
+)
+
+/*
+Index is a trigram posting index. Each trigram maps to the set of keys
+containing it. Keys are stored lower case, so lookups are case
+insensitive like the index tree.
+*/
+type Index struct {
+	postings map[string]map[string]struct{}
+	keys     map[string]struct{}
+}
+
+/*
+Add records a key in the index. Adding a key which is already present
+does nothing.
+*/
+func (index *Index) Add(key string) {
+	key = strings.ToLower(key)
+
+	if _, ok := index.keys[key]; ok {
+		return
+	}
+
+	index.keys[key] = struct{}{}
+
+	for _, trigram := range trigrams(key) {
+		posting, ok := index.postings[trigram]
+		if !ok {
+			posting = make(map[string]struct{})
+			index.postings[trigram] = posting
+		}
+
+		posting[key] = struct{}{}
+	}
+}
+
+/*
+Contains returns, in lexical order, the keys which contain a search
+term. Only keys listed under every trigram of the search term are
+checked. Search terms shorter than three characters are checked against
+every key.
+*/
+func (index *Index) Contains(searchTerm string) []string {
+	searchTerm = strings.ToLower(searchTerm)
+	results := make([]string, 0)
+
+	for key := range index.candidates(searchTerm) {
+		if strings.Contains(key, searchTerm) {
+			results = append(results, key)
+		}
+	}
+
+	sort.Strings(results)
+	return results
+}
+
+/*
+candidates returns the set of keys which have every trigram of the
+search term. The smallest posting list is used as the starting set.
+*/
+func (index *Index) candidates(searchTerm string) map[string]struct{} {
+	searchTrigrams := trigrams(searchTerm)
+	if len(searchTrigrams) == 0 {
+		return index.keys
+	}
+
+	var postings []map[string]struct{}
+
+	for _, trigram := range searchTrigrams {
+		posting, ok := index.postings[trigram]
+		if !ok {
+			return nil
+		}
+
+		postings = append(postings, posting)
+	}
+
+	sort.Slice(postings, func(i, j int) bool {
+		return len(postings[i]) < len(postings[j])
+	})
+
+	result := make(map[string]struct{})
+
+	for key := range postings[0] {
+		found := true
+
+		for _, posting := range postings[1:] {
+			if _, ok := posting[key]; !ok {
+				found = false
+				break
+			}
+		}
+
+		if found {
+			result[key] = struct{}{}
+		}
+	}
+
+	return result
+}
+
+/*
+NewIndex creates a new, empty trigram index.
+*/
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string]struct{}),
+		keys:     make(map[string]struct{}),
+	}
+}
+
+/*
+Remove deletes a key from the index. Trigrams left without keys are
+dropped.
+*/
+func (index *Index) Remove(key string) {
+	key = strings.ToLower(key)
+
+	if _, ok := index.keys[key]; !ok {
+		return
+	}
+
+	delete(index.keys, key)
+
+	for _, trigram := range trigrams(key) {
+		if posting, ok := index.postings[trigram]; ok {
+			delete(posting, key)
+
+			if len(posting) == 0 {
+				delete(index.postings, trigram)
+			}
+		}
+	}
+}
+
+/*
+trigrams returns the distinct three rune sequences in a string.
+*/
+func trigrams(value string) []string {
+	runes := []rune(value)
+	if len(runes) < 3 {
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+	result := make([]string, 0, len(runes)-2)
+
+	for index := 0; index+3 <= len(runes); index++ {
+		trigram := string(runes[index : index+3])
+
+		if _, ok := seen[trigram]; !ok {
+			seen[trigram] = struct{}{}
+			result = append(result, trigram)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,6 @@
+/*
+Package trigram provides a posting index of the three character
+sequences found in term keys. It is used to narrow substring searches
+down to a small set of candidate keys instead of scanning every key.
+*/
+package trigram